@@ -2,8 +2,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"sort"
 	"strings"
 
 	"github.com/Simon-Busch/abi_simplifier/parser"
@@ -13,13 +16,25 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "storage-diff" {
+		os.Exit(runStorageDiff(os.Args[2:]))
+	}
+
+	strict := flag.Bool("strict", false, "exit with status 1 when loading reports any warning or error")
+	flag.Parse()
+
 	dataFolder := "data"
 
-	contracts, err := parser.ParseAllContracts(dataFolder)
+	units, report, err := parser.LoadSourceUnits(dataFolder)
 	if err != nil {
-		fmt.Println("Error parsing contract files:", err)
-		return
+		fmt.Fprintln(os.Stderr, "Error parsing contract files:", err)
+		os.Exit(1)
+	}
+	if *strict && report.Diagnostics.Failed() {
+		printDiagnostics(report.Diagnostics)
+		os.Exit(1)
 	}
+	contracts := parser.CollectContracts(units)
 
 	if err := termui.Init(); err != nil {
 		log.Fatalf("failed to initialize termui: %v", err)
@@ -40,17 +55,29 @@ func main() {
 	codeParagraph := widgets.NewParagraph()
 	codeParagraph.Title = "Code"
 	codeParagraph.WrapText = true
+	codeParagraph.Text = loadSummary(report)
 
-	// Populate contracts list
-	var contractNames []string
-	for name := range contracts {
-		contractNames = append(contractNames, name)
-	}
-	contractsList.Rows = contractNames
+	diagnosticsList := widgets.NewList()
+	diagnosticsList.Title = "Diagnostics"
+	diagnosticsList.TextStyle = termui.NewStyle(termui.ColorWhite)
+	diagnosticsList.WrapText = false
+	diagnosticsList.Rows = diagnosticRows(report.Diagnostics)
+	var diagnosticsPanel *widgets.List // The diagnostics list while shown with 'd', nil otherwise
+
+	// Populate contracts list, keeping the qualified key of each row
+	listKeys, listRows := contractRows(contracts)
+	contractsList.Rows = listRows
 	contractsList.SelectedRow = 0
 
 	// Variables to keep track of selections
 	var selectedContract *parser.Contract
+	var selectedKey string
+	var detailIDs []int // AST node ID of the item shown on each details row
+	var effectiveView = false // Declared members only until toggled with 'e'
+	var sourcesView = false   // Contracts are listed until toggled with 's'
+	var showUnderlying = false // Underlying types of value types are shown when toggled with 'u'
+	var searching = false      // Keystrokes build the selector query after '/'
+	var searchQuery string
 	var contractsListSelected = true  // Initially, contracts list is selected
 	var detailsListSelected = false   // Details list is not selected
 
@@ -58,6 +85,7 @@ func main() {
 		contractsList,
 		detailsList,
 		codeParagraph,
+		diagnosticsPanel,
 		contractsListSelected,
 		detailsListSelected,
 	)
@@ -66,14 +94,45 @@ func main() {
 	uiEvents := termui.PollEvents()
 	for {
 		e := <-uiEvents
+		if searching && e.ID != "<C-c>" {
+			// Selector search input, results are refreshed on every keystroke
+			switch e.ID {
+			case "<Enter>", "<Escape>":
+				searching = false
+			case "<Backspace>", "<C-<Backspace>>":
+				if len(searchQuery) > 0 {
+					searchQuery = searchQuery[:len(searchQuery)-1]
+				}
+			default:
+				if len(e.ID) == 1 {
+					searchQuery += e.ID
+				}
+			}
+			codeParagraph.Text = searchSelector(contracts, searchQuery)
+			ui.UpdateUI(
+				contractsList,
+				detailsList,
+				codeParagraph,
+				diagnosticsPanel,
+				contractsListSelected,
+				detailsListSelected,
+			)
+			continue
+		}
 		switch e.ID {
 		case "q", "<C-c>":
 			return
+		case "/":
+			// Start a search by selector hex
+			searching = true
+			searchQuery = ""
+			codeParagraph.Text = searchSelector(contracts, searchQuery)
 		case "<Resize>":
 			ui.UpdateUI(
 				contractsList,
 				detailsList,
 				codeParagraph,
+				diagnosticsPanel,
 				contractsListSelected,
 				detailsListSelected,
 			)
@@ -103,74 +162,14 @@ func main() {
 				if len(contractsList.Rows) == 0 {
 					continue
 				}
-				contractName := contractsList.Rows[contractsList.SelectedRow]
-				contract := contracts[contractName]
-				selectedContract = contract
-
-				// Populate details list with functions, variables, events, structs, enums
-				var details []string
-
-				// Constructor
-				if selectedContract.Constructor != nil {
-					details = append(details, "[Constructor](fg:cyan)")
-					details = append(details, "  - Constructor")
-				}
-
-				// Functions
-				details = append(details, "[Functions](fg:cyan)")
-				for _, function := range contract.Functions {
-					details = append(details, "  "+function.Name)
-				}
-
-				// Mappings
-				details = append(details, "[Mappings](fg:cyan)")
-				for _, mapping := range selectedContract.Mappings {
-					details = append(details, "  "+mapping.Name)
-				}
-
-				// Constants
-				details = append(details, "[Constants](fg:cyan)")
-				for _, constant := range selectedContract.Constants {
-					details = append(details, "  "+constant.Name)
-				}
-				// Variables
-				details = append(details, "[Variables](fg:cyan)")
-				for _, variable := range contract.Variables {
-					details = append(details, "  "+variable.Name)
-				}
-
-				// Events
-				details = append(details, "[Events](fg:cyan)")
-				for _, event := range contract.Events {
-					details = append(details, "  "+event.Name)
-				}
-
-				// Structs
-				details = append(details, "[Structs](fg:cyan)")
-				for _, strct := range contract.Structs {
-					details = append(details, "  "+strct.Name)
-				}
-
-				// Enums
-				details = append(details, "[Enums](fg:cyan)")
-				for _, enum := range contract.Enums {
-					details = append(details, "  "+enum.Name)
-				}
-
-				detailsList.Rows = details
-				detailsList.SelectedRow = 0 // Reset SelectedRow
-
-				detailsList.Title = "Details of " + contract.Name
-
-				// Update code paragraph with contract summary
-				codeText := fmt.Sprintf("Contract: %s\n", contract.Name)
-				codeText += fmt.Sprintf("Pragma: %s\n", contract.Pragma)
-				if len(contract.Inherits) > 0 {
-					codeText += fmt.Sprintf("Inherits: %v\n", contract.Inherits)
+				selectedKey = listKeys[contractsList.SelectedRow]
+				if sourcesView {
+					selectedContract = freeDeclarations(units[selectedKey])
+					detailIDs = showContract(selectedContract, detailsList, codeParagraph, false)
+					codeParagraph.Text = sourceUnitSummary(units[selectedKey])
 				} else {
-					codeText += "Inherits: None\n"
+					selectedContract, detailIDs = showContractByKey(contracts, units, selectedKey, detailsList, codeParagraph, effectiveView)
 				}
-				codeParagraph.Text = codeText
 
 				// Switch selection to details list
 				detailsListSelected = true
@@ -179,6 +178,7 @@ func main() {
 						contractsList,
 						detailsList,
 						codeParagraph,
+						diagnosticsPanel,
 						contractsListSelected,
 						detailsListSelected,
 				)
@@ -204,7 +204,8 @@ func main() {
 					}
 				}
 
-				itemName := strings.TrimSpace(selectedRow)
+				// Items are resolved by AST node ID, display text is ambiguous for overloads
+				itemID := detailIDs[detailsList.SelectedRow]
 				switch itemType {
 				case "Constructor":
 					// Display constructor details
@@ -213,7 +214,7 @@ func main() {
 					if len(constructor.Parameters) > 0 {
 							constructorDetails += "Parameters:\n"
 							for _, param := range constructor.Parameters {
-									constructorDetails += fmt.Sprintf("  - %s: %s\n", param.Name, param.Type)
+									constructorDetails += fmt.Sprintf("  - %s: %s\n", param.Name, param.DisplayType(showUnderlying))
 							}
 					}
 					if len(constructor.Modifiers) > 0 {
@@ -225,48 +226,39 @@ func main() {
 					constructorDetails += fmt.Sprintf("Visibility: %s\n", constructor.Visibility)
 					constructorDetails += fmt.Sprintf("State Mutability: %s\n", constructor.StateMutability)
 					codeParagraph.Text = constructorDetails
+				case "Fallback":
+					codeParagraph.Text = specialFunctionDetails("Fallback", selectedContract.Fallback)
+				case "Receive":
+					codeParagraph.Text = specialFunctionDetails("Receive", selectedContract.Receive)
 				case "Functions":
 					var selectedFunction parser.Function
 					for _, fn := range selectedContract.Functions {
-						if fn.Name == itemName {
+						if fn.ID == itemID {
 							selectedFunction = fn
 							break
 						}
 					}
-					// Display function details
-					functionDetails := fmt.Sprintf("Function: %s\n", selectedFunction.Name)
-					if len(selectedFunction.Parameters) > 0 {
-						functionDetails += "Parameters:\n"
-						for _, param := range selectedFunction.Parameters {
-							functionDetails += fmt.Sprintf("  - %s: %s\n", param.Name, param.Type)
-						}
-					}
-					if len(selectedFunction.ReturnParameters) > 0 {
-						functionDetails += "Returns:\n"
-						for _, param := range selectedFunction.ReturnParameters {
-							functionDetails += fmt.Sprintf("  - %s: %s\n", param.Name, param.Type)
-						}
-					}
-					if len(selectedFunction.Modifiers) > 0 {
-						functionDetails += "Modifiers:\n"
-						for _, mod := range selectedFunction.Modifiers {
-							functionDetails += fmt.Sprintf("  - %s\n", mod)
+					codeParagraph.Text = functionDetails(selectedFunction, showUnderlying)
+				case "External API":
+					var selectedFunction parser.Function
+					for _, fn := range append(externalFunctions(selectedContract), selectedContract.Getters...) {
+						if fn.ID == itemID {
+							selectedFunction = fn
+							break
 						}
 					}
-					functionDetails += fmt.Sprintf("Visibility: %s\n", selectedFunction.Visibility)
-					functionDetails += fmt.Sprintf("State Mutability: %s\n", selectedFunction.StateMutability)
-					codeParagraph.Text = functionDetails
+					codeParagraph.Text = functionDetails(selectedFunction, showUnderlying)
 				case "Constants":
 					var selectedConstant parser.Variable
 					for _, c := range selectedContract.Constants {
-						if c.Name == itemName {
+						if c.ID == itemID {
 							selectedConstant = c
 							break
 						}
 					}
 					// Display constant details
 					constantDetails := fmt.Sprintf("Constant: %s\n", selectedConstant.Name)
-					constantDetails += fmt.Sprintf("Type: %s\n", selectedConstant.Type)
+					constantDetails += fmt.Sprintf("Type: %s\n", selectedConstant.DisplayType(showUnderlying))
 					constantDetails += fmt.Sprintf("Visibility: %s\n", selectedConstant.Visibility)
 					if selectedConstant.Value != "" {
 						constantDetails += fmt.Sprintf("Value: %s\n", selectedConstant.Value)
@@ -275,14 +267,17 @@ func main() {
 				case "Variables":
 					var selectedVariable parser.Variable
 					for _, v := range selectedContract.Variables {
-						if v.Name == itemName {
+						if v.ID == itemID {
 							selectedVariable = v
 							break
 						}
 					}
 					// Display variable details
 					variableDetails := fmt.Sprintf("Variable: %s\n", selectedVariable.Name)
-					variableDetails += fmt.Sprintf("Type: %s\n", selectedVariable.Type)
+					variableDetails += fmt.Sprintf("Type: %s\n", selectedVariable.DisplayType(showUnderlying))
+					if canonical := selectedVariable.TypeInfo.Canonical(); canonical != "" && canonical != selectedVariable.Type {
+						variableDetails += fmt.Sprintf("ABI Type: %s\n", canonical)
+					}
 					variableDetails += fmt.Sprintf("Visibility: %s\n", selectedVariable.Visibility)
 					if selectedVariable.Constant {
 						variableDetails += "Constant: true\n"
@@ -297,13 +292,14 @@ func main() {
 				case "Events":
 					var selectedEvent parser.Event
 					for _, e := range selectedContract.Events {
-						if e.Name == itemName {
+						if e.ID == itemID {
 							selectedEvent = e
 							break
 						}
 					}
 					// Display event details
 					eventDetails := fmt.Sprintf("Event: %s\n", selectedEvent.Name)
+					eventDetails += fmt.Sprintf("Signature: %s\n", selectedEvent.Signature)
 					if len(selectedEvent.Parameters) > 0 {
 						eventDetails += "Parameters:\n"
 						for _, param := range selectedEvent.Parameters {
@@ -311,14 +307,115 @@ func main() {
 							if param.Indexed {
 								indexedStr = "(indexed)"
 							}
-							eventDetails += fmt.Sprintf("  - %s %s %s\n", param.Type, param.Name, indexedStr)
+							eventDetails += fmt.Sprintf("  - %s %s %s\n", param.DisplayType(showUnderlying), param.Name, indexedStr)
 						}
 					}
+					if selectedEvent.Anonymous {
+						eventDetails += "Anonymous: true\n"
+						eventDetails += "Topic0: none (anonymous event)\n"
+					} else {
+						eventDetails += fmt.Sprintf("Topic0: 0x%s\n", selectedEvent.Topic0)
+					}
+					if selectedEvent.Warning != "" {
+						eventDetails += fmt.Sprintf("[Warning: %s](fg:red)\n", selectedEvent.Warning)
+					}
 					codeParagraph.Text = eventDetails
+				case "Errors":
+					var selectedError parser.Error
+					for _, e := range selectedContract.Errors {
+						if e.ID == itemID {
+							selectedError = e
+							break
+						}
+					}
+					// Display error details
+					errorDetails := fmt.Sprintf("Error: %s\n", selectedError.Name)
+					if len(selectedError.Parameters) > 0 {
+						errorDetails += "Parameters:\n"
+						for _, param := range selectedError.Parameters {
+							errorDetails += fmt.Sprintf("  - %s: %s\n", param.Name, param.DisplayType(showUnderlying))
+						}
+					}
+					errorDetails += fmt.Sprintf("Signature: %s\n", selectedError.Signature)
+					errorDetails += fmt.Sprintf("Selector: 0x%s\n", selectedError.Selector)
+					codeParagraph.Text = errorDetails
+				case "Value Types":
+					var selectedValueType parser.UserDefinedValueType
+					for _, v := range selectedContract.ValueTypes {
+						if v.ID == itemID {
+							selectedValueType = v
+							break
+						}
+					}
+					// Display value type details
+					valueTypeDetails := fmt.Sprintf("Value Type: %s\n", selectedValueType.Name)
+					valueTypeDetails += fmt.Sprintf("Underlying Type: %s\n", selectedValueType.UnderlyingType)
+					codeParagraph.Text = valueTypeDetails
+				case "Using For":
+					var selectedUsingFor parser.UsingFor
+					for _, u := range selectedContract.UsingFor {
+						if u.ID == itemID {
+							selectedUsingFor = u
+							break
+						}
+					}
+					// Display using-for details
+					usingForDetails := ""
+					if selectedUsingFor.Library != "" {
+						usingForDetails += fmt.Sprintf("Library: %s\n", selectedUsingFor.Library)
+					}
+					if len(selectedUsingFor.Functions) > 0 {
+						usingForDetails += "Functions:\n"
+						for _, fn := range selectedUsingFor.Functions {
+							usingForDetails += fmt.Sprintf("  - %s\n", fn)
+						}
+					}
+					usingForDetails += fmt.Sprintf("For: %s\n", selectedUsingFor.TypeName)
+					if selectedUsingFor.Global {
+						usingForDetails += "Global: true\n"
+					}
+					codeParagraph.Text = usingForDetails
+				case "Storage":
+					layout, _, _ := parser.ContractStorageLayout(contracts[selectedKey], contracts)
+					var selectedEntry parser.StorageEntry
+					for _, entry := range layout {
+						if entry.ID == itemID {
+							selectedEntry = entry
+							break
+						}
+					}
+					// Display storage entry details
+					storageDetails := fmt.Sprintf("Variable: %s\n", selectedEntry.Label)
+					storageDetails += fmt.Sprintf("Type: %s\n", selectedEntry.Type)
+					storageDetails += fmt.Sprintf("Slot: %d\n", selectedEntry.Slot)
+					storageDetails += fmt.Sprintf("Offset: %d\n", selectedEntry.Offset)
+					storageDetails += fmt.Sprintf("Size: %d bytes\n", selectedEntry.Size)
+					storageDetails += fmt.Sprintf("Declared in: %s\n", selectedEntry.Contract)
+					codeParagraph.Text = storageDetails
+				case "Sources":
+					codeParagraph.Text = sourceDetails(selectedContract.Metadata, strings.TrimSpace(selectedRow))
+				case "Modifiers":
+					var selectedModifier parser.Modifier
+					for _, m := range selectedContract.Modifiers {
+						if m.ID == itemID {
+							selectedModifier = m
+							break
+						}
+					}
+					// Display modifier details
+					modifierDetails := fmt.Sprintf("Modifier: %s\n", selectedModifier.Name)
+					if len(selectedModifier.Parameters) > 0 {
+						modifierDetails += "Parameters:\n"
+						for _, param := range selectedModifier.Parameters {
+							modifierDetails += fmt.Sprintf("  - %s: %s\n", param.Name, param.DisplayType(showUnderlying))
+						}
+					}
+					modifierDetails += fmt.Sprintf("Declared in: %s\n", selectedModifier.DeclaredIn)
+					codeParagraph.Text = modifierDetails
 				case "Structs":
 					var selectedStruct parser.Struct
 					for _, s := range selectedContract.Structs {
-						if s.Name == itemName {
+						if s.ID == itemID {
 							selectedStruct = s
 							break
 						}
@@ -328,14 +425,14 @@ func main() {
 					if len(selectedStruct.Members) > 0 {
 						structDetails += "Members:\n"
 						for _, member := range selectedStruct.Members {
-							structDetails += fmt.Sprintf("  - %s: %s\n", member.Name, member.Type)
+							structDetails += fmt.Sprintf("  - %s: %s\n", member.Name, member.DisplayType(showUnderlying))
 						}
 					}
 					codeParagraph.Text = structDetails
 				case "Enums":
 					var selectedEnum parser.Enum
 					for _, e := range selectedContract.Enums {
-						if e.Name == itemName {
+						if e.ID == itemID {
 							selectedEnum = e
 							break
 						}
@@ -352,7 +449,7 @@ func main() {
 				case "Mappings":
 					var selectedMapping parser.Variable
 					for _, m := range selectedContract.Mappings {
-						if m.Name == itemName {
+						if m.ID == itemID {
 							selectedMapping = m
 							break
 						}
@@ -367,10 +464,51 @@ func main() {
 					contractsList,
 					detailsList,
 					codeParagraph,
+					diagnosticsPanel,
 					contractsListSelected,
 					detailsListSelected,
 				)
 			}
+		case "e":
+			// Toggle between declared and effective (inherited) members
+			effectiveView = !effectiveView
+			if selectedContract != nil && !sourcesView {
+				selectedContract, detailIDs = showContractByKey(contracts, units, selectedKey, detailsList, codeParagraph, effectiveView)
+			}
+		case "u":
+			// Toggle the underlying type display of user-defined value types
+			showUnderlying = !showUnderlying
+		case "d":
+			// Toggle the diagnostics panel
+			if diagnosticsPanel == nil {
+				diagnosticsPanel = diagnosticsList
+			} else {
+				diagnosticsPanel = nil
+				termui.Clear()
+			}
+		case "<PageDown>":
+			if diagnosticsPanel != nil && len(diagnosticsPanel.Rows) > 0 {
+				diagnosticsPanel.ScrollDown()
+			}
+		case "<PageUp>":
+			if diagnosticsPanel != nil && len(diagnosticsPanel.Rows) > 0 {
+				diagnosticsPanel.ScrollUp()
+			}
+		case "s":
+			// Toggle the left panel between contracts and source files
+			if !contractsListSelected {
+				continue
+			}
+			sourcesView = !sourcesView
+			if sourcesView {
+				listKeys, listRows = sourceRows(units)
+				contractsList.Title = "Sources"
+			} else {
+				listKeys, listRows = contractRows(contracts)
+				contractsList.Title = "Contracts"
+			}
+			contractsList.Rows = listRows
+			contractsList.SelectedRow = 0
 		case "<Left>":
 			if detailsListSelected {
 				// Go back to contracts list
@@ -385,6 +523,7 @@ func main() {
 					contractsList,
 					detailsList,
 					codeParagraph,
+					diagnosticsPanel,
 					contractsListSelected,
 					detailsListSelected,
 				)
@@ -394,8 +533,572 @@ func main() {
 			contractsList,
 			detailsList,
 			codeParagraph,
+			diagnosticsPanel,
 			contractsListSelected,
 			detailsListSelected,
 		)
 	}
 }
+
+// contractTag returns the list prefix marking interfaces, libraries and abstract contracts.
+func contractTag(contract *parser.Contract) string {
+	switch {
+	case contract.Kind == "interface":
+		return "[I] "
+	case contract.Kind == "library":
+		return "[L] "
+	case contract.Abstract:
+		return "[A] "
+	}
+	return ""
+}
+
+// contractRows returns the sorted qualified contract keys and their list rows.
+func contractRows(contracts map[string]*parser.Contract) ([]string, []string) {
+	var keys []string
+	for key := range contracts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	displayNames := parser.DisplayNames(contracts)
+	var rows []string
+	for _, key := range keys {
+		rows = append(rows, contractTag(contracts[key])+displayNames[key])
+	}
+	return keys, rows
+}
+
+// sourceRows returns the sorted source paths and their list rows, with the
+// number of file-level declarations of each source.
+func sourceRows(units map[string]*parser.SourceUnit) ([]string, []string) {
+	var keys []string
+	for key := range units {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var rows []string
+	for _, key := range keys {
+		unit := units[key]
+		count := len(unit.Functions) + len(unit.Constants) + len(unit.Events) + len(unit.Errors) + len(unit.Structs) + len(unit.Enums) + len(unit.ValueTypes) + len(unit.UsingFor)
+		rows = append(rows, fmt.Sprintf("%s (%d)", key, count))
+	}
+	return keys, rows
+}
+
+// freeDeclarations wraps the file-level declarations of a source unit in a
+// Contract so they can be browsed like contract members.
+func freeDeclarations(unit *parser.SourceUnit) *parser.Contract {
+	return &parser.Contract{
+		Name:       unit.Path,
+		SourcePath: unit.Path,
+		Pragma:     unit.Pragma,
+		Imports:    unit.Imports,
+		Functions:  unit.Functions,
+		Constants:  unit.Constants,
+		Events:     unit.Events,
+		Errors:     unit.Errors,
+		ValueTypes: unit.ValueTypes,
+		UsingFor:   unit.UsingFor,
+		Structs:    unit.Structs,
+		Enums:      unit.Enums,
+	}
+}
+
+// sourceUnitSummary describes a source file and the contracts it declares.
+func sourceUnitSummary(unit *parser.SourceUnit) string {
+	summary := fmt.Sprintf("Source: %s\n", unit.Path)
+	summary += fmt.Sprintf("Pragma: %s\n", unit.Pragma)
+	if len(unit.Imports) > 0 {
+		summary += "Imports:\n"
+		for _, imp := range unit.Imports {
+			summary += fmt.Sprintf("  - %s\n", imp.AbsolutePath)
+		}
+	}
+	summary += "Contracts:\n"
+	for _, contract := range unit.Contracts {
+		summary += fmt.Sprintf("  - %s%s\n", contractTag(contract), contract.Name)
+	}
+	return summary
+}
+
+// showContractByKey shows the contract stored under key, with the checks and
+// the storage layout that need the other parsed contracts. It returns the
+// contract shown and the AST node ID of each details row.
+func showContractByKey(contracts map[string]*parser.Contract, units map[string]*parser.SourceUnit, key string, detailsList *widgets.List, codeParagraph *widgets.Paragraph, effective bool) (*parser.Contract, []int) {
+	contract := contractView(contracts, key, effective)
+	ids := showContract(contract, detailsList, codeParagraph, effective)
+	codeParagraph.Text += interfaceConstantsReport(contracts, key)
+	codeParagraph.Text += abiReport(contracts, units, key)
+
+	// Storage layout, interfaces and libraries have no storage, and Vyper
+	// contracts do not follow the solc layout
+	if contract.Kind != "contract" || contract.ASTFlavour == parser.ASTVyper {
+		return contract, ids
+	}
+	detailsList.Rows = append(detailsList.Rows, "[Storage](fg:cyan)")
+	ids = append(ids, 0)
+	layout, reported, err := parser.ContractStorageLayout(contracts[key], contracts)
+	for _, entry := range layout {
+		detailsList.Rows = append(detailsList.Rows, storageRow(entry))
+		ids = append(ids, entry.ID)
+	}
+	if err != nil {
+		codeParagraph.Text += fmt.Sprintf("[Storage layout incomplete: %s](fg:red)\n", err)
+	}
+	if reported {
+		codeParagraph.Text += "Storage layout: reported by compiler\n"
+	} else {
+		codeParagraph.Text += "Storage layout: computed\n"
+	}
+	for _, discrepancy := range parser.CheckStorageLayout(contracts[key], contracts) {
+		codeParagraph.Text += fmt.Sprintf("[Storage mismatch: %s](fg:red)\n", discrepancy)
+	}
+	return contract, ids
+}
+
+// sourceDetails describes a source file of the compilation a contract was
+// verified with.
+func sourceDetails(metadata *parser.Metadata, path string) string {
+	if metadata == nil {
+		return ""
+	}
+	for _, source := range metadata.Sources {
+		if source.Path != path {
+			continue
+		}
+		details := fmt.Sprintf("Source: %s\n", source.Path)
+		if source.License != "" {
+			details += fmt.Sprintf("License: %s\n", source.License)
+		}
+		if source.Keccak256 != "" {
+			details += fmt.Sprintf("Keccak256: %s\n", source.Keccak256)
+		}
+		for _, url := range source.URLs {
+			details += fmt.Sprintf("URL: %s\n", url)
+		}
+		if !source.Available {
+			details += "[Content not found in the bundle](fg:yellow)\n"
+		}
+		if source.Error != "" {
+			details += fmt.Sprintf("[Parse error: %s](fg:red)\n", source.Error)
+		}
+		return details
+	}
+	return ""
+}
+
+// storageRow renders a storage entry as slot, offset, size, label, type and
+// declaring contract.
+func storageRow(entry parser.StorageEntry) string {
+	return fmt.Sprintf("  %d:%d (%dB) %s: %s [(%s)](fg:yellow)", entry.Slot, entry.Offset, entry.Size, entry.Label, entry.Type, entry.Contract)
+}
+
+// contractView returns the contract stored under key, flattened over its
+// inheritance chain when the effective view is enabled.
+func contractView(contracts map[string]*parser.Contract, key string, effective bool) *parser.Contract {
+	contract := contracts[key]
+	if effective {
+		return parser.ResolveEffective(contract, contracts)
+	}
+	return contract
+}
+
+// showContract fills the details list and the summary paragraph for a contract.
+// It returns the AST node ID of the item on each details row, 0 for headers.
+func showContract(contract *parser.Contract, detailsList *widgets.List, codeParagraph *widgets.Paragraph, effective bool) []int {
+	// Populate details list with functions, variables, events, structs, enums
+	var details []string
+	var ids []int
+	header := func(title string) {
+		details = append(details, "["+title+"](fg:cyan)")
+		ids = append(ids, 0)
+	}
+	item := func(row string, id int) {
+		details = append(details, row)
+		ids = append(ids, id)
+	}
+
+	// Constructor
+	if contract.Constructor != nil {
+		header("Constructor")
+		item("  - Constructor", contract.Constructor.ID)
+	}
+
+	// Fallback
+	if contract.Fallback != nil {
+		header("Fallback")
+		item(memberRow(contract, "- Fallback", contract.Fallback.DeclaredIn, false), contract.Fallback.ID)
+	}
+
+	// Receive
+	if contract.Receive != nil {
+		header("Receive")
+		item(memberRow(contract, "- Receive", contract.Receive.DeclaredIn, false), contract.Receive.ID)
+	}
+
+	// Functions, shown with their full signature when overloaded
+	overloads := make(map[string]int)
+	for _, function := range contract.Functions {
+		overloads[function.Name]++
+	}
+	header("Functions")
+	for _, function := range contract.Functions {
+		label := function.Name
+		if overloads[function.Name] > 1 {
+			label = function.Signature
+		}
+		item(memberRow(contract, label, function.DeclaredIn, function.Overridden), function.ID)
+	}
+
+	// External API: externally callable functions and public state variable getters
+	header("External API")
+	for _, function := range externalFunctions(contract) {
+		item(memberRow(contract, function.Signature, function.DeclaredIn, function.Overridden), function.ID)
+	}
+	for _, getter := range contract.Getters {
+		item(memberRow(contract, getter.Signature+" [getter](fg:magenta)", getter.DeclaredIn, getter.Overridden), getter.ID)
+	}
+
+	// Mappings
+	header("Mappings")
+	for _, mapping := range contract.Mappings {
+		item(memberRow(contract, mapping.Name, mapping.DeclaredIn, mapping.Overridden), mapping.ID)
+	}
+
+	// Constants
+	header("Constants")
+	for _, constant := range contract.Constants {
+		item(memberRow(contract, constant.Name, constant.DeclaredIn, constant.Overridden), constant.ID)
+	}
+	// Variables
+	header("Variables")
+	for _, variable := range contract.Variables {
+		item(memberRow(contract, variable.Name, variable.DeclaredIn, variable.Overridden), variable.ID)
+	}
+
+	// Events
+	header("Events")
+	for _, event := range contract.Events {
+		item(memberRow(contract, event.Name, event.DeclaredIn, false), event.ID)
+	}
+
+	// Value types
+	header("Value Types")
+	for _, valueType := range contract.ValueTypes {
+		item("  "+valueType.Name, valueType.ID)
+	}
+
+	// Using for
+	header("Using For")
+	for _, usingFor := range contract.UsingFor {
+		item(fmt.Sprintf("  %s for %s", usingForLabel(usingFor), usingFor.TypeName), usingFor.ID)
+	}
+
+	// Errors
+	header("Errors")
+	for _, customError := range contract.Errors {
+		item(memberRow(contract, customError.Name, customError.DeclaredIn, false), customError.ID)
+	}
+
+	// Modifiers
+	header("Modifiers")
+	for _, modifier := range contract.Modifiers {
+		item(memberRow(contract, modifier.Name, modifier.DeclaredIn, modifier.Overridden), modifier.ID)
+	}
+
+	// Structs
+	header("Structs")
+	for _, strct := range contract.Structs {
+		item("  "+strct.Name, strct.ID)
+	}
+
+	// Enums
+	header("Enums")
+	for _, enum := range contract.Enums {
+		item("  "+enum.Name, enum.ID)
+	}
+
+	// Sources of the verified compilation, which have no AST node
+	if contract.Metadata != nil {
+		header("Sources")
+		for _, source := range contract.Metadata.Sources {
+			item("  "+source.Path, 0)
+		}
+	}
+
+	detailsList.Rows = details
+	detailsList.SelectedRow = 0 // Reset SelectedRow
+
+	detailsList.Title = "Details of " + contract.Name
+	if effective {
+		detailsList.Title += " (effective)"
+	}
+
+	// Update code paragraph with contract summary
+	codeText := fmt.Sprintf("Contract: %s\n", contract.Name)
+	codeText += fmt.Sprintf("Source: %s\n", contract.SourcePath)
+	codeText += fmt.Sprintf("Kind: %s\n", contract.Kind)
+	switch contract.ASTFlavour {
+	case parser.ASTLegacy:
+		codeText += "AST: legacy (solc 0.4)\n"
+	case parser.ASTVyper:
+		codeText += "AST: Vyper\n"
+	case parser.ASTSource:
+		codeText += "AST: parsed from source\n"
+	}
+	if contract.Abstract {
+		codeText += "Abstract: true\n"
+	}
+	codeText += fmt.Sprintf("Pragma: %s\n", contract.Pragma)
+	if len(contract.Inherits) > 0 {
+		codeText += fmt.Sprintf("Inherits: %v\n", contract.Inherits)
+	} else {
+		codeText += "Inherits: None\n"
+	}
+	if len(contract.Linearized) > 1 {
+		codeText += fmt.Sprintf("Linearization: %s\n", strings.Join(contract.Linearized, " -> "))
+	}
+	if contract.InterfaceID != "" {
+		codeText += fmt.Sprintf("Interface ID: 0x%s\n", contract.InterfaceID)
+	}
+	if contract.ExternalInterfaceID != "" {
+		codeText += fmt.Sprintf("External Interface ID: 0x%s\n", contract.ExternalInterfaceID)
+	}
+	if contract.ABI != nil {
+		codeText += fmt.Sprintf("ABI: %d functions, %d events, %d errors\n", len(contract.ABI.Methods), len(contract.ABI.Events), len(contract.ABI.Errors))
+	}
+	if metadata := contract.Metadata; metadata != nil {
+		codeText += fmt.Sprintf("Compiler: %s %s (%s)\n", metadata.Language, metadata.CompilerVersion, metadata.Origin)
+		codeText += fmt.Sprintf("Settings: %s\n", metadata.Settings())
+		if metadata.Address != "" {
+			codeText += fmt.Sprintf("Address: %s (chain %s)\n", metadata.Address, metadata.ChainID)
+		}
+		if len(metadata.Remappings) > 0 {
+			codeText += fmt.Sprintf("Remappings: %s\n", strings.Join(metadata.Remappings, ", "))
+		}
+		var libraries []string
+		for name, address := range metadata.Libraries {
+			libraries = append(libraries, name+"="+address)
+		}
+		sort.Strings(libraries)
+		if len(libraries) > 0 {
+			codeText += fmt.Sprintf("Libraries: %s\n", strings.Join(libraries, ", "))
+		}
+		codeText += fmt.Sprintf("Sources: %d files\n", len(metadata.Sources))
+	}
+	codeParagraph.Text = codeText
+	return ids
+}
+
+// functionDetails describes a function or a public state variable getter.
+func functionDetails(function parser.Function, showUnderlying bool) string {
+	details := fmt.Sprintf("Function: %s\n", function.Name)
+	if function.Getter {
+		details += "Getter of public state variable\n"
+	}
+	details += fmt.Sprintf("Signature: %s\n", function.Signature)
+	if function.Selector != "" {
+		details += fmt.Sprintf("Selector: 0x%s\n", function.Selector)
+	}
+	if len(function.Parameters) > 0 {
+		details += "Parameters:\n"
+		for _, param := range function.Parameters {
+			details += fmt.Sprintf("  - %s: %s\n", param.Name, param.DisplayType(showUnderlying))
+		}
+	}
+	if len(function.ReturnParameters) > 0 {
+		details += "Returns:\n"
+		for _, param := range function.ReturnParameters {
+			details += fmt.Sprintf("  - %s: %s\n", param.Name, param.DisplayType(showUnderlying))
+		}
+	}
+	if len(function.Modifiers) > 0 {
+		details += "Modifiers:\n"
+		for _, mod := range function.Modifiers {
+			details += fmt.Sprintf("  - %s\n", mod)
+		}
+	}
+	details += fmt.Sprintf("Visibility: %s\n", function.Visibility)
+	details += fmt.Sprintf("State Mutability: %s\n", function.StateMutability)
+	details += fmt.Sprintf("Declared in: %s\n", function.DeclaredIn)
+	return details
+}
+
+// externalFunctions returns the public and external functions of a contract.
+func externalFunctions(contract *parser.Contract) []parser.Function {
+	var functions []parser.Function
+	for _, function := range contract.Functions {
+		if function.Visibility == "public" || function.Visibility == "external" {
+			functions = append(functions, function)
+		}
+	}
+	return functions
+}
+
+// specialFunctionDetails describes a fallback or receive function.
+func specialFunctionDetails(title string, function *parser.Function) string {
+	details := title + "\n"
+	if function.StateMutability == "payable" {
+		details += "Payable: true\n"
+	} else {
+		details += "Payable: false\n"
+	}
+	if len(function.Parameters) > 0 {
+		details += "Parameters:\n"
+		for _, param := range function.Parameters {
+			details += fmt.Sprintf("  - %s: %s\n", param.Name, param.Type)
+		}
+	}
+	if len(function.ReturnParameters) > 0 {
+		details += "Returns:\n"
+		for _, param := range function.ReturnParameters {
+			details += fmt.Sprintf("  - %s: %s\n", param.Name, param.Type)
+		}
+	}
+	if len(function.Modifiers) > 0 {
+		details += "Modifiers:\n"
+		for _, mod := range function.Modifiers {
+			details += fmt.Sprintf("  - %s\n", mod)
+		}
+	}
+	details += fmt.Sprintf("Visibility: %s\n", function.Visibility)
+	details += fmt.Sprintf("State Mutability: %s\n", function.StateMutability)
+	details += fmt.Sprintf("Declared in: %s\n", function.DeclaredIn)
+	return details
+}
+
+// loadSummary describes the files found in the data folder, shown until a
+// contract is selected.
+func loadSummary(report *parser.LoadReport) string {
+	summary := report.Summary() + "\n"
+	for _, file := range report.Skipped() {
+		summary += fmt.Sprintf("[Skipped %s (%s): %s](fg:yellow)\n", file.Path, file.Format, file.Skipped)
+	}
+	diags := report.Diagnostics
+	if errors, warnings := diags.Count(parser.SeverityError), diags.Count(parser.SeverityWarning); errors+warnings > 0 {
+		summary += fmt.Sprintf("[%d errors, %d warnings, press d to show the diagnostics](fg:red)\n", errors, warnings)
+	}
+	return summary
+}
+
+// diagnosticRows renders one row per diagnostic, with the severity colored.
+func diagnosticRows(diags *parser.Diagnostics) []string {
+	var rows []string
+	for _, diagnostic := range diags.All() {
+		color := "white"
+		switch diagnostic.Severity {
+		case parser.SeverityError:
+			color = "red"
+		case parser.SeverityWarning:
+			color = "yellow"
+		}
+		rows = append(rows, fmt.Sprintf("[%s](fg:%s) %s: %s", diagnostic.Severity, color, diagnostic.Location(), diagnostic.Message))
+	}
+	return rows
+}
+
+// printDiagnostics writes the errors and warnings to stderr, for a strict load.
+func printDiagnostics(diags *parser.Diagnostics) {
+	for _, diagnostic := range diags.All() {
+		if diagnostic.Severity != parser.SeverityInfo {
+			fmt.Fprintln(os.Stderr, diagnostic)
+		}
+	}
+}
+
+// abiReport lists the differences between the artifact ABI of a contract and
+// its AST.
+func abiReport(contracts map[string]*parser.Contract, units map[string]*parser.SourceUnit, key string) string {
+	findings := parser.CheckABI(contracts[key], contracts, units)
+	if len(findings) == 0 {
+		return ""
+	}
+	report := "[ABI mismatches:](fg:red)\n"
+	for _, finding := range findings {
+		report += fmt.Sprintf("  - %s\n", finding)
+	}
+	return report
+}
+
+// interfaceConstantsReport lists the supportsInterface constants of a contract
+// that do not match the interface IDs of its inherited interfaces. Constants
+// not named as interface IDs are only noted.
+func interfaceConstantsReport(contracts map[string]*parser.Contract, key string) string {
+	findings, notes, err := parser.CheckInterfaceConstants(contracts[key], contracts)
+	if err != nil {
+		return fmt.Sprintf("[Interface IDs not verified: %s](fg:yellow)\n", err)
+	}
+	report := ""
+	if len(findings) > 0 {
+		report += "[Interface ID mismatches:](fg:red)\n"
+		for _, finding := range findings {
+			report += fmt.Sprintf("  - %s\n", finding)
+		}
+	}
+	if len(notes) > 0 {
+		report += "Other bytes4 constants:\n"
+		for _, note := range notes {
+			report += fmt.Sprintf("  - %s\n", note)
+		}
+	}
+	return report
+}
+
+// searchSelector lists the functions, public state variable getters and
+// errors whose selector starts with the hex query.
+func searchSelector(contracts map[string]*parser.Contract, query string) string {
+	prefix := strings.TrimPrefix(strings.ToLower(query), "0x")
+	result := fmt.Sprintf("Selector search: 0x%s\n(Enter or Esc to close)\n\n", prefix)
+	if prefix == "" {
+		return result
+	}
+
+	var keys []string
+	for key := range contracts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		contract := contracts[key]
+		for _, function := range contract.Functions {
+			if function.Selector != "" && strings.HasPrefix(function.Selector, prefix) {
+				result += fmt.Sprintf("0x%s %s in %s\n", function.Selector, function.Signature, key)
+			}
+		}
+		// Getters carry computed selectors, so the ones of contracts parsed
+		// from source, Vyper and legacy ASTs are found too
+		for _, getter := range contract.Getters {
+			if strings.HasPrefix(getter.Selector, prefix) {
+				result += fmt.Sprintf("0x%s %s (getter) in %s\n", getter.Selector, getter.Signature, key)
+			}
+		}
+		for _, customError := range contract.Errors {
+			if strings.HasPrefix(customError.Selector, prefix) {
+				result += fmt.Sprintf("0x%s %s (error) in %s\n", customError.Selector, customError.Signature, key)
+			}
+		}
+	}
+	return result
+}
+
+// usingForLabel renders a using-for directive by its library name, or by the
+// attached function list.
+func usingForLabel(usingFor parser.UsingFor) string {
+	if usingFor.Library != "" {
+		return usingFor.Library
+	}
+	return "{" + strings.Join(usingFor.Functions, ", ") + "}"
+}
+
+// memberRow renders a details row, tagging members inherited from another
+// contract and members overridden further down the inheritance chain.
+func memberRow(contract *parser.Contract, name string, declaredIn string, overridden bool) string {
+	row := "  " + name
+	if declaredIn != "" && declaredIn != contract.Name {
+		row += fmt.Sprintf(" [(%s)](fg:yellow)", declaredIn)
+	}
+	if overridden {
+		row += " [overridden](fg:red)"
+	}
+	return row
+}
@@ -0,0 +1,125 @@
+// abi.go
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// DecodeABI decodes the abi array of an artifact.
+func DecodeABI(data []byte) (*abi.ABI, error) {
+	decoded, err := abi.JSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return &decoded, nil
+}
+
+// CheckABI compares the ABI of the artifact a contract was read from with the
+// external surface derived from its AST, and describes every function, event
+// and error found on one side only. Errors and events may be declared anywhere,
+// such as in a library or at file level, so those listed in the ABI only need a
+// declaration somewhere in the parsed sources. Declared errors the contract
+// never reverts with are left out of the ABI by the compiler and not reported.
+// It returns nothing when the artifact has no ABI.
+func CheckABI(contract *Contract, contracts map[string]*Contract, units map[string]*SourceUnit) []string {
+	if contract.ABI == nil {
+		return nil
+	}
+	var findings []string
+	effective := ResolveEffective(contract, contracts)
+
+	// Functions, including the getters of public state variables
+	astFunctions := make(map[string]bool)
+	for _, functions := range [][]Function{effective.Functions, effective.Getters} {
+		for _, function := range functions {
+			if isExternallyCallable(function) && !function.Overridden {
+				astFunctions[function.Signature] = true
+			}
+		}
+	}
+	abiFunctions := make(map[string]bool)
+	for _, method := range contract.ABI.Methods {
+		abiFunctions[method.Sig] = true
+		if !astFunctions[method.Sig] {
+			findings = append(findings, fmt.Sprintf("function %s is in the ABI but not in the AST", method.Sig))
+		}
+	}
+	for signature := range astFunctions {
+		if !abiFunctions[signature] {
+			findings = append(findings, fmt.Sprintf("function %s is in the AST but not in the ABI", signature))
+		}
+	}
+
+	// Events
+	declaredEvents, declaredErrors := declaredSignatures(contracts, units)
+	astEvents := make(map[string]bool)
+	for _, event := range effective.Events {
+		astEvents[event.Signature] = true
+	}
+	abiEvents := make(map[string]bool)
+	for _, event := range contract.ABI.Events {
+		abiEvents[event.Sig] = true
+		if !astEvents[event.Sig] && !declaredEvents[event.Sig] {
+			findings = append(findings, fmt.Sprintf("event %s is in the ABI but not in the AST", event.Sig))
+		}
+	}
+	for signature := range astEvents {
+		if !abiEvents[signature] {
+			findings = append(findings, fmt.Sprintf("event %s is in the AST but not in the ABI", signature))
+		}
+	}
+
+	// Errors
+	for _, abiError := range contract.ABI.Errors {
+		if !declaredErrors[abiError.Sig] {
+			findings = append(findings, fmt.Sprintf("error %s is in the ABI but not in the AST", abiError.Sig))
+		}
+	}
+
+	// Fallback and receive
+	if contract.ABI.HasFallback() != (effective.Fallback != nil) {
+		findings = append(findings, fmt.Sprintf("fallback function is only in the %s", presentIn(contract.ABI.HasFallback())))
+	}
+	if contract.ABI.HasReceive() != (effective.Receive != nil) {
+		findings = append(findings, fmt.Sprintf("receive function is only in the %s", presentIn(contract.ABI.HasReceive())))
+	}
+
+	sort.Strings(findings)
+	return findings
+}
+
+// declaredSignatures returns the signatures of every event and error declared
+// in the parsed sources.
+func declaredSignatures(contracts map[string]*Contract, units map[string]*SourceUnit) (map[string]bool, map[string]bool) {
+	events := make(map[string]bool)
+	errors := make(map[string]bool)
+	for _, contract := range contracts {
+		for _, event := range contract.Events {
+			events[event.Signature] = true
+		}
+		for _, e := range contract.Errors {
+			errors[e.Signature] = true
+		}
+	}
+	for _, unit := range units {
+		for _, event := range unit.Events {
+			events[event.Signature] = true
+		}
+		for _, e := range unit.Errors {
+			errors[e.Signature] = true
+		}
+	}
+	return events, errors
+}
+
+// presentIn names the side a function is found on.
+func presentIn(inABI bool) string {
+	if inABI {
+		return "ABI"
+	}
+	return "AST"
+}
@@ -0,0 +1,71 @@
+// abi_test.go
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckABI(t *testing.T) {
+	src := `event Deposited(address indexed account, uint256 amount);
+		error Unauthorized(address account);
+		contract Vault {
+			event Withdrawn(address indexed account, uint256 amount);
+			function deposit() external payable {}
+		}`
+	tests := []struct {
+		name string
+		abi  string
+		want []string
+	}{
+		{
+			name: "matching",
+			abi: `[
+				{"type": "function", "name": "deposit", "inputs": [], "outputs": [], "stateMutability": "payable"},
+				{"type": "event", "name": "Withdrawn", "inputs": [{"name": "account", "type": "address", "indexed": true}, {"name": "amount", "type": "uint256", "indexed": false}], "anonymous": false},
+				{"type": "event", "name": "Deposited", "inputs": [{"name": "account", "type": "address", "indexed": true}, {"name": "amount", "type": "uint256", "indexed": false}], "anonymous": false},
+				{"type": "error", "name": "Unauthorized", "inputs": [{"name": "account", "type": "address"}]}
+			]`,
+		},
+		{
+			name: "mismatching",
+			abi: `[
+				{"type": "function", "name": "withdraw", "inputs": [], "outputs": [], "stateMutability": "nonpayable"},
+				{"type": "event", "name": "Paused", "inputs": [], "anonymous": false},
+				{"type": "error", "name": "Paused", "inputs": []},
+				{"type": "receive", "stateMutability": "payable"}
+			]`,
+			want: []string{
+				"error Paused() is in the ABI but not in the AST",
+				"event Paused() is in the ABI but not in the AST",
+				"event Withdrawn(address,uint256) is in the AST but not in the ABI",
+				"function deposit() is in the AST but not in the ABI",
+				"function withdraw() is in the ABI but not in the AST",
+				"receive function is only in the ABI",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ast, err := ParseSolidity("Vault.sol", src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			unit, err := ExtractSourceUnit(ast, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			units := map[string]*SourceUnit{"Vault.sol": unit}
+			resolveUnits(units, nil)
+			contracts := CollectContracts(units)
+			contract := unit.Contracts[0]
+			if contract.ABI, err = DecodeABI([]byte(test.abi)); err != nil {
+				t.Fatal(err)
+			}
+			got := CheckABI(contract, contracts, units)
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("findings = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
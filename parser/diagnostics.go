@@ -0,0 +1,117 @@
+// diagnostics.go
+package parser
+
+import (
+	"fmt"
+)
+
+// Severities of a Diagnostic.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// Diagnostic is a problem found while reading the data folder. It is located
+// by file and, when it concerns an AST node, by node ID and src range.
+type Diagnostic struct {
+	Severity string
+	File     string
+	NodeID   int    // 0 when the problem is not about a node
+	Src      string // start:length:sourceIndex of the node, empty when unknown
+	Message  string
+}
+
+// String renders the diagnostic on one line, such as
+// "warning: src/Token.sol #12 (120:8:0): unhandled expression MemberAccess".
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s", d.Severity, d.Location(), d.Message)
+}
+
+// Location renders the file, node ID and src range of the diagnostic.
+func (d Diagnostic) Location() string {
+	location := d.File
+	if d.NodeID != 0 {
+		location += fmt.Sprintf(" #%d", d.NodeID)
+	}
+	if d.Src != "" {
+		location += fmt.Sprintf(" (%s)", d.Src)
+	}
+	return location
+}
+
+// Diagnostics collects the diagnostics of a load. The collector returned by
+// ForFile shares the list of the one it comes from, and locates what it
+// collects in the given file. A nil collector drops everything, so that
+// extraction can run without one.
+type Diagnostics struct {
+	file string
+	list *[]Diagnostic
+}
+
+// NewDiagnostics returns an empty collector.
+func NewDiagnostics() *Diagnostics {
+	return &Diagnostics{list: &[]Diagnostic{}}
+}
+
+// ForFile returns a collector for the diagnostics of a file.
+func (d *Diagnostics) ForFile(file string) *Diagnostics {
+	if d == nil {
+		return nil
+	}
+	return &Diagnostics{file: file, list: d.list}
+}
+
+// Errorf records an error about a node, or about the file when nodeID is 0.
+func (d *Diagnostics) Errorf(nodeID int, src string, format string, args ...interface{}) {
+	d.add(SeverityError, nodeID, src, fmt.Sprintf(format, args...))
+}
+
+// Warnf records a warning about a node, or about the file when nodeID is 0.
+func (d *Diagnostics) Warnf(nodeID int, src string, format string, args ...interface{}) {
+	d.add(SeverityWarning, nodeID, src, fmt.Sprintf(format, args...))
+}
+
+// Infof records a note about a node, or about the file when nodeID is 0.
+func (d *Diagnostics) Infof(nodeID int, src string, format string, args ...interface{}) {
+	d.add(SeverityInfo, nodeID, src, fmt.Sprintf(format, args...))
+}
+
+// add records a diagnostic, once: the same node may be visited more than once,
+// e.g. for the display string and the structured form of a type.
+func (d *Diagnostics) add(severity string, nodeID int, src string, message string) {
+	if d == nil {
+		return
+	}
+	diagnostic := Diagnostic{Severity: severity, File: d.file, NodeID: nodeID, Src: src, Message: message}
+	for _, existing := range *d.list {
+		if existing == diagnostic {
+			return
+		}
+	}
+	*d.list = append(*d.list, diagnostic)
+}
+
+// All returns the diagnostics in the order they were recorded.
+func (d *Diagnostics) All() []Diagnostic {
+	if d == nil {
+		return nil
+	}
+	return *d.list
+}
+
+// Count returns the number of diagnostics of a severity.
+func (d *Diagnostics) Count(severity string) int {
+	count := 0
+	for _, diagnostic := range d.All() {
+		if diagnostic.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
+
+// Failed reports whether a strict load fails: any error or warning does.
+func (d *Diagnostics) Failed() bool {
+	return d.Count(SeverityError) > 0 || d.Count(SeverityWarning) > 0
+}
@@ -0,0 +1,161 @@
+// formats.go
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FileFormat is the kind of compiler output a JSON file holds, or Solidity
+// source code.
+type FileFormat string
+
+const (
+	FormatFoundryArtifact FileFormat = "Foundry artifact"
+	FormatVyperAST        FileFormat = "Vyper AST"
+	FormatBuildInfo       FileFormat = "build-info"
+	FormatStandardJSON    FileFormat = "standard-JSON"
+	FormatABIOnly         FileFormat = "ABI-only"
+	FormatSolidity        FileFormat = "Solidity source"
+	FormatSourcify        FileFormat = "Sourcify metadata"
+	FormatEtherscan       FileFormat = "Etherscan verified source"
+	FormatUnknown         FileFormat = "unknown"
+)
+
+// SniffFormat classifies a JSON file from its top-level fields, without
+// decoding the AST.
+func SniffFormat(data []byte) FileFormat {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		// A bare ABI is an array of entries
+		var entries []struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(data, &entries) == nil && len(entries) > 0 && entries[0].Type != "" {
+			return FormatABIOnly
+		}
+		if hasEntryField(data, "SourceCode") {
+			return FormatEtherscan
+		}
+		return FormatUnknown
+	}
+	switch {
+	case hasField(fields["output"], "sources", "contracts"):
+		return FormatBuildInfo
+	case fields["compiler"] != nil && fields["language"] != nil && fields["sources"] != nil:
+		return FormatSourcify
+	case fields["SourceCode"] != nil || hasEntryField(fields["result"], "SourceCode"):
+		return FormatEtherscan
+	case fields["sources"] != nil && fields["language"] == nil:
+		// Standard-JSON inputs and metadata files also list sources, but name the language
+		return FormatStandardJSON
+	case hasField(fields["ast"], "ast_type"):
+		return FormatVyperAST
+	case isPresent(fields["ast"]):
+		return FormatFoundryArtifact
+	case isPresent(fields["abi"]):
+		return FormatABIOnly
+	}
+	return FormatUnknown
+}
+
+// hasField reports whether raw is an object with any of the given fields.
+func hasField(raw json.RawMessage, names ...string) bool {
+	var fields map[string]json.RawMessage
+	if !isPresent(raw) || json.Unmarshal(raw, &fields) != nil {
+		return false
+	}
+	for _, name := range names {
+		if fields[name] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// hasEntryField reports whether raw is an array whose first entry has the given
+// field.
+func hasEntryField(raw json.RawMessage, name string) bool {
+	var entries []map[string]json.RawMessage
+	if !isPresent(raw) || json.Unmarshal(raw, &entries) != nil || len(entries) == 0 {
+		return false
+	}
+	return entries[0][name] != nil
+}
+
+// isPresent reports whether a field was set to something other than null.
+func isPresent(raw json.RawMessage) bool {
+	return raw != nil && string(raw) != "null"
+}
+
+// LoadedFile records what was done with a JSON or Solidity file of the data
+// folder.
+type LoadedFile struct {
+	Path    string
+	Format  FileFormat
+	Units   int    // Source units extracted from the file
+	Skipped string // Reason the file was skipped, empty when it was loaded
+}
+
+// LoadReport lists the JSON and Solidity files found in a data folder, and the
+// diagnostics collected while reading them.
+type LoadReport struct {
+	Files       []LoadedFile
+	Diagnostics *Diagnostics
+}
+
+// add records a file, and the reason it was skipped. Files that are not meant
+// to be read, such as ABI-only files and caches, are only noted.
+func (report *LoadReport) add(file LoadedFile, diags *Diagnostics) {
+	report.Files = append(report.Files, file)
+	switch {
+	case file.Skipped == "":
+	case file.Format == FormatABIOnly || file.Format == FormatUnknown:
+		diags.Infof(0, "", "skipped %s file: %s", file.Format, file.Skipped)
+	default:
+		diags.Errorf(0, "", "skipped %s file: %s", file.Format, file.Skipped)
+	}
+}
+
+// Loaded returns the files source units were extracted from.
+func (report *LoadReport) Loaded() []LoadedFile {
+	var files []LoadedFile
+	for _, file := range report.Files {
+		if file.Skipped == "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// Skipped returns the files that were skipped, with the reason.
+func (report *LoadReport) Skipped() []LoadedFile {
+	var files []LoadedFile
+	for _, file := range report.Files {
+		if file.Skipped != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// Summary counts the loaded files by format, such as
+// "3 files loaded (2 Foundry artifact, 1 build-info), 1 skipped".
+func (report *LoadReport) Summary() string {
+	counts := make(map[FileFormat]int)
+	for _, file := range report.Loaded() {
+		counts[file.Format]++
+	}
+	var formats []string
+	for format, count := range counts {
+		formats = append(formats, fmt.Sprintf("%d %s", count, format))
+	}
+	sort.Strings(formats)
+	summary := fmt.Sprintf("%d files loaded", len(report.Loaded()))
+	if len(formats) > 0 {
+		summary += fmt.Sprintf(" (%s)", strings.Join(formats, ", "))
+	}
+	return summary + fmt.Sprintf(", %d skipped", len(report.Skipped()))
+}
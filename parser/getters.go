@@ -0,0 +1,70 @@
+// getters.go
+package parser
+
+// ResolveGetters builds the compiler-generated getter of every public state
+// variable. Struct members are looked up in the source units, so this runs
+// once all of them are parsed.
+func ResolveGetters(units map[string]*SourceUnit) {
+	registry := newTypeRegistry(units)
+	for _, unit := range units {
+		for _, contract := range unit.Contracts {
+			contract.Getters = nil
+			for _, variables := range [][]Variable{contract.Variables, contract.Constants, contract.Mappings} {
+				for _, variable := range variables {
+					if variable.Visibility == "public" {
+						contract.Getters = append(contract.Getters, registry.getter(unit.Compilation, variable))
+					}
+				}
+			}
+		}
+	}
+}
+
+// getter describes the getter of a public state variable of the given
+// compilation: mapping keys and array indices become parameters, and structs
+// return their members except mappings and arrays.
+func (r *typeRegistry) getter(compilation string, variable Variable) Function {
+	getter := Function{
+		ID:              variable.ID,
+		Name:            variable.Name,
+		Kind:            "function",
+		Visibility:      "external",
+		StateMutability: "view",
+		DeclaredIn:      variable.DeclaredIn,
+		Getter:          true,
+	}
+
+	t := variable.TypeInfo
+	for t != nil && (t.Kind == TypeMapping || t.Kind == TypeArray) {
+		if t.Kind == TypeMapping {
+			getter.Parameters = append(getter.Parameters, Parameter{Type: t.KeyType.Display, TypeInfo: t.KeyType})
+			t = t.ValueType
+		} else {
+			getter.Parameters = append(getter.Parameters, Parameter{Type: "uint256", TypeInfo: &Type{Kind: TypeElementary, Name: "uint256", Display: "uint256"}})
+			t = t.BaseType
+		}
+	}
+
+	if strct, ok := r.structs[declarationKey{compilation, structReference(t)}]; ok {
+		for _, member := range strct.Members {
+			if member.TypeInfo != nil && (member.TypeInfo.Kind == TypeMapping || member.TypeInfo.Kind == TypeArray) {
+				continue
+			}
+			getter.ReturnParameters = append(getter.ReturnParameters, Parameter{Name: member.Name, Type: member.Type, TypeInfo: member.TypeInfo})
+		}
+	} else if t != nil {
+		getter.ReturnParameters = append(getter.ReturnParameters, Parameter{Type: t.Display, TypeInfo: t})
+	}
+
+	getter.Signature = CanonicalSignature(getter.Name, getter.Parameters)
+	getter.Selector = Selector(getter.Signature)
+	return getter
+}
+
+// structReference returns the declaration ID of a struct type, or 0.
+func structReference(t *Type) int {
+	if t == nil || t.Kind != TypeUserDefined || t.DeclarationKind != "struct" {
+		return 0
+	}
+	return t.ReferencedDeclaration
+}
@@ -0,0 +1,133 @@
+// inheritance.go
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResolveEffective builds the effective interface of a contract by walking its
+// linearized inheritance chain, most derived contract first. Every member keeps
+// the name of the contract declaring it, and members redeclared further down the
+// chain are kept but marked as overridden.
+func ResolveEffective(contract *Contract, contracts map[string]*Contract) *Contract {
+	effective := &Contract{
+		ID:                      contract.ID,
+		Name:                    contract.Name,
+		SourcePath:              contract.SourcePath,
+		Kind:                    contract.Kind,
+		Abstract:                contract.Abstract,
+		Pragma:                  contract.Pragma,
+		Imports:                 contract.Imports,
+		Inherits:                contract.Inherits,
+		LinearizedBaseContracts: contract.LinearizedBaseContracts,
+		Linearized:              contract.Linearized,
+		InterfaceID:             contract.InterfaceID,
+		ExternalInterfaceID:     contract.ExternalInterfaceID,
+		Constructor:             contract.Constructor,
+		ABI:                     contract.ABI,
+		ASTFlavour:              contract.ASTFlavour,
+		Metadata:                contract.Metadata,
+		Compilation:             contract.Compilation,
+		Structs:                 contract.Structs,
+		Enums:                   contract.Enums,
+		ValueTypes:              contract.ValueTypes,
+		UsingFor:                contract.UsingFor,
+	}
+
+	seenFunctions := make(map[string]bool)
+	seenModifiers := make(map[string]bool)
+	seenVariables := make(map[string]bool)
+	chain, _ := linearizedContracts(contract, contracts)
+	for _, base := range chain {
+		// The most derived fallback and receive functions are the ones in effect
+		if effective.Fallback == nil {
+			effective.Fallback = base.Fallback
+		}
+		if effective.Receive == nil {
+			effective.Receive = base.Receive
+		}
+		for _, function := range base.Functions {
+			key := functionKey(function)
+			function.Overridden = seenFunctions[key] || seenVariables[function.Name]
+			seenFunctions[key] = true
+			effective.Functions = append(effective.Functions, function)
+		}
+		for _, modifier := range base.Modifiers {
+			modifier.Overridden = seenModifiers[modifier.Name]
+			seenModifiers[modifier.Name] = true
+			effective.Modifiers = append(effective.Modifiers, modifier)
+		}
+		for _, getter := range base.Getters {
+			getter.Overridden = seenVariables[getter.Name]
+			effective.Getters = append(effective.Getters, getter)
+		}
+		effective.Events = append(effective.Events, base.Events...)
+		effective.Errors = append(effective.Errors, base.Errors...)
+		effective.Variables = append(effective.Variables, markVariables(base.Variables, seenVariables)...)
+		effective.Constants = append(effective.Constants, markVariables(base.Constants, seenVariables)...)
+		effective.Mappings = append(effective.Mappings, markVariables(base.Mappings, seenVariables)...)
+	}
+	return effective
+}
+
+// linearizedContracts returns the parsed contracts of the linearization of
+// contract, skipping bases that are not part of the data folder. The skipped
+// bases are returned as #id, or by name for contracts parsed from source, whose
+// linearization leaves out the bases it could not find.
+func linearizedContracts(contract *Contract, contracts map[string]*Contract) ([]*Contract, []string) {
+	var chain []*Contract
+	var missing []string
+	if len(contract.LinearizedBaseContracts) == 0 {
+		chain = []*Contract{contract}
+	} else {
+		byKey := contractsByKey(contracts)
+		// The contract itself always resolves, even if another unit reuses its ID
+		byKey[declarationKey{contract.Compilation, contract.ID}] = contract
+
+		for _, id := range contract.LinearizedBaseContracts {
+			if base, ok := byKey[declarationKey{contract.Compilation, id}]; ok {
+				chain = append(chain, base)
+			} else {
+				missing = append(missing, fmt.Sprintf("#%d", id))
+			}
+		}
+	}
+	if contract.ASTFlavour == ASTSource {
+		for _, name := range contract.Inherits {
+			name = name[strings.LastIndex(name, ".")+1:]
+			found := false
+			for _, base := range chain {
+				if base.Name == name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				missing = append(missing, name)
+			}
+		}
+	}
+	return chain, missing
+}
+
+// markVariables copies variables, flagging the ones shadowing an already seen name.
+func markVariables(variables []Variable, seen map[string]bool) []Variable {
+	var marked []Variable
+	for _, variable := range variables {
+		variable.Overridden = seen[variable.Name]
+		seen[variable.Name] = true
+		marked = append(marked, variable)
+	}
+	return marked
+}
+
+// functionKey identifies a function by name and parameter types, which is what
+// an override has to match.
+func functionKey(function Function) string {
+	var types []string
+	for _, param := range function.Parameters {
+		types = append(types, param.Type)
+	}
+	return function.Name + "(" + strings.Join(types, ",") + ")"
+}
@@ -0,0 +1,101 @@
+// interfaces.go
+package parser
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// InterfaceID returns the EIP-165 identifier of a set of functions: the XOR of
+// the selectors of the externally callable ones, hex encoded.
+func InterfaceID(functions []Function) string {
+	id := make([]byte, 4)
+	for _, function := range functions {
+		if function.Overridden || !isExternallyCallable(function) {
+			continue
+		}
+		selector, err := hex.DecodeString(function.Selector)
+		if err != nil || len(selector) != 4 {
+			continue
+		}
+		for i := range id {
+			id[i] ^= selector[i]
+		}
+	}
+	return hex.EncodeToString(id)
+}
+
+// ResolveInterfaceIDs sets the interface IDs of all contracts. For interfaces
+// InterfaceID matches type(I).interfaceId, which excludes inherited functions;
+// ExternalInterfaceID covers the whole external surface, inherited functions
+// and public state variable getters included.
+func ResolveInterfaceIDs(contracts map[string]*Contract) {
+	for _, contract := range contracts {
+		if contract.Kind == "interface" {
+			contract.InterfaceID = InterfaceID(contract.Functions)
+		}
+		effective := ResolveEffective(contract, contracts)
+		contract.ExternalInterfaceID = InterfaceID(append(effective.Functions, effective.Getters...))
+	}
+}
+
+// CheckInterfaceConstants reports the bytes4 constants of a contract
+// implementing supportsInterface whose value is not the interface ID of any
+// interface the contract inherits. Function bodies are not parsed, so the
+// constants supportsInterface compares with are not known: only those named as
+// interface IDs, such as _INTERFACE_ID_ERC721, are findings. The others, often
+// magic return values such as the onERC721Received selector, are returned as
+// notes. The constants are not checked when a base contract is missing from
+// the data folder, as it may be the interface they are the ID of, e.g. IERC165
+// from a library that was not loaded.
+func CheckInterfaceConstants(contract *Contract, contracts map[string]*Contract) ([]string, []string, error) {
+	effective := ResolveEffective(contract, contracts)
+	supportsInterface := false
+	for _, function := range effective.Functions {
+		if function.Name == "supportsInterface" {
+			supportsInterface = true
+			break
+		}
+	}
+	if !supportsInterface {
+		return nil, nil, nil
+	}
+
+	chain, missing := linearizedContracts(contract, contracts)
+	if len(missing) > 0 {
+		return nil, nil, fmt.Errorf("base contracts not found: %s", strings.Join(missing, ", "))
+	}
+	inherited := make(map[string]string)
+	for _, base := range chain {
+		if base.Kind == "interface" {
+			inherited[base.InterfaceID] = base.Name
+		}
+	}
+
+	var findings, notes []string
+	for _, constant := range effective.Constants {
+		value := strings.ToLower(constant.Value)
+		// Only literal values can be checked, e.g. 0x80ac58cd
+		if constant.Type != "bytes4" || !strings.HasPrefix(value, "0x") {
+			continue
+		}
+		if _, ok := inherited[strings.TrimPrefix(value, "0x")]; ok {
+			continue
+		}
+		message := fmt.Sprintf("%s = %s does not match any inherited interface ID", constant.Name, constant.Value)
+		if isInterfaceIDName(constant.Name) {
+			findings = append(findings, message)
+		} else {
+			notes = append(notes, message)
+		}
+	}
+	return findings, notes, nil
+}
+
+// isInterfaceIDName reports whether a constant is named as an interface ID,
+// such as _INTERFACE_ID_ERC721 or ERC2981_INTERFACE_ID.
+func isInterfaceIDName(name string) bool {
+	name = strings.ToLower(strings.ReplaceAll(name, "_", ""))
+	return strings.Contains(name, "interfaceid")
+}
@@ -0,0 +1,91 @@
+// interfaces_test.go
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckInterfaceConstants(t *testing.T) {
+	erc165 := `interface IERC165 { function supportsInterface(bytes4 interfaceId) external view returns (bool); }`
+	tests := []struct {
+		name      string
+		src       string
+		findings  []string
+		notes     []string
+		wantError string
+	}{
+		{
+			name: "inherited interface ID",
+			src: erc165 + `contract NFT is IERC165 {
+				bytes4 private constant _INTERFACE_ID_ERC165 = 0x01ffc9a7;
+				function supportsInterface(bytes4 id) external view returns (bool) {}
+			}`,
+		},
+		{
+			name: "interface ID of an interface not inherited",
+			src: erc165 + `contract NFT is IERC165 {
+				bytes4 private constant _INTERFACE_ID_ERC721 = 0x80ac58cd;
+				bytes4 constant ERC2981_INTERFACE_ID = 0x2a55205a;
+				function supportsInterface(bytes4 id) external view returns (bool) {}
+			}`,
+			findings: []string{
+				"_INTERFACE_ID_ERC721 = 0x80ac58cd does not match any inherited interface ID",
+				"ERC2981_INTERFACE_ID = 0x2a55205a does not match any inherited interface ID",
+			},
+		},
+		{
+			name: "magic return values",
+			src: erc165 + `contract Wallet is IERC165 {
+				bytes4 private constant _ERC721_RECEIVED = 0x150b7a02;
+				bytes4 internal constant MAGICVALUE = 0x1626ba7e;
+				function supportsInterface(bytes4 id) external view returns (bool) {}
+			}`,
+			notes: []string{
+				"_ERC721_RECEIVED = 0x150b7a02 does not match any inherited interface ID",
+				"MAGICVALUE = 0x1626ba7e does not match any inherited interface ID",
+			},
+		},
+		{
+			name: "without supportsInterface",
+			src: `contract Receiver {
+				bytes4 private constant _INTERFACE_ID_ERC721 = 0x80ac58cd;
+			}`,
+		},
+		{
+			name: "missing base",
+			src: `contract NFT is ERC165 {
+				bytes4 private constant _INTERFACE_ID_ERC721 = 0x80ac58cd;
+				function supportsInterface(bytes4 id) external view returns (bool) {}
+			}`,
+			wantError: "base contracts not found: ERC165",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contracts := parseContracts(t, map[string]string{"A.sol": test.src}, nil)
+			var contract *Contract
+			for _, c := range contracts {
+				if c.Kind == "contract" {
+					contract = c
+				}
+			}
+			findings, notes, err := CheckInterfaceConstants(contract, contracts)
+			if test.wantError != "" {
+				if err == nil || err.Error() != test.wantError {
+					t.Errorf("error = %v, want %q", err, test.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(findings, "\n") != strings.Join(test.findings, "\n") {
+				t.Errorf("findings = %q, want %q", findings, test.findings)
+			}
+			if strings.Join(notes, "\n") != strings.Join(test.notes, "\n") {
+				t.Errorf("notes = %q, want %q", notes, test.notes)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+// legacy.go
+package parser
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// AST flavours, recorded on each contract.
+const (
+	ASTCompact = "compact" // nodeType and nodes, solc 0.5 and later
+	ASTLegacy  = "legacy"  // name, attributes and children, solc 0.4
+)
+
+// LegacyASTNode is a node of the legacy solc AST, where the node type is in
+// name, the properties in attributes and the child nodes in children.
+type LegacyASTNode struct {
+	ID         int                        `json:"id"`
+	Name       string                     `json:"name"`
+	Src        string                     `json:"src,omitempty"`
+	Attributes map[string]json.RawMessage `json:"attributes,omitempty"`
+	Children   []LegacyASTNode            `json:"children,omitempty"`
+}
+
+// UnmarshalJSON decodes a compact, a legacy or a Vyper AST, normalising legacy
+// and Vyper trees into the shape of the compact one.
+func (ast *AST) UnmarshalJSON(data []byte) error {
+	var probe struct {
+		NodeType string `json:"nodeType"`
+		Name     string `json:"name"`
+		ASTType  string `json:"ast_type"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return err
+	}
+	if probe.ASTType == "Module" {
+		var module VyperNode
+		if err := json.Unmarshal(data, &module); err != nil {
+			return err
+		}
+		*ast = NormalizeVyperAST(module)
+		return nil
+	}
+	if probe.NodeType == "" && probe.Name == "SourceUnit" {
+		var legacy LegacyASTNode
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return err
+		}
+		*ast = NormalizeLegacyAST(legacy)
+		return nil
+	}
+
+	// Decode through a type without this method to avoid the recursion
+	type compactAST AST
+	var compact compactAST
+	if err := json.Unmarshal(data, &compact); err != nil {
+		return err
+	}
+	*ast = AST(compact)
+	ast.Flavour = ASTCompact
+	return nil
+}
+
+// NormalizeLegacyAST converts a legacy SourceUnit node to an AST.
+func NormalizeLegacyAST(unit LegacyASTNode) AST {
+	ast := AST{
+		AbsolutePath: unit.stringAttribute("absolutePath"),
+		Flavour:      ASTLegacy,
+	}
+	for _, child := range unit.Children {
+		ast.Nodes = append(ast.Nodes, normalizeLegacyNode(child))
+	}
+	return ast
+}
+
+// normalizeLegacyNode converts a legacy declaration or expression node.
+func normalizeLegacyNode(legacy LegacyASTNode) ASTNode {
+	node := ASTNode{
+		ID:       legacy.ID,
+		Src:      legacy.Src,
+		NodeType: legacy.Name,
+		Name:     legacy.stringAttribute("name"),
+	}
+	switch legacy.Name {
+	case "PragmaDirective":
+		legacy.attribute("literals", &node.Literals)
+	case "ImportDirective":
+		node.AbsolutePath = legacy.stringAttribute("absolutePath")
+		node.File = legacy.stringAttribute("file")
+		node.Name = legacy.stringAttribute("unitAlias")
+	case "ContractDefinition":
+		node.ContractKind = legacy.stringAttribute("contractKind")
+		if node.ContractKind == "" {
+			node.ContractKind = "contract"
+		}
+		legacy.attribute("linearizedBaseContracts", &node.LinearizedBaseContracts)
+		for _, child := range legacy.Children {
+			if child.Name == "InheritanceSpecifier" && len(child.Children) > 0 {
+				node.BaseContracts = append(node.BaseContracts, BaseContract{
+					BaseName: BaseName{Name: child.Children[0].stringAttribute("name")},
+				})
+				continue
+			}
+			node.Nodes = append(node.Nodes, normalizeLegacyNode(child))
+		}
+	case "VariableDeclaration":
+		node.Visibility = legacy.stringAttribute("visibility")
+		node.StorageLocation = legacy.stringAttribute("storageLocation")
+		legacy.attribute("constant", &node.Constant)
+		legacy.attribute("stateVariable", &node.StateVariable)
+		if node.Constant {
+			node.Mutability = "constant"
+		} else {
+			node.Mutability = "mutable"
+		}
+		if _, ok := legacy.Attributes["indexed"]; ok {
+			var indexed bool
+			legacy.attribute("indexed", &indexed)
+			node.Indexed = &indexed
+		}
+		// The type name comes first, followed by the initial value
+		if len(legacy.Children) > 0 {
+			node.TypeName = normalizeLegacyTypeName(legacy.Children[0])
+		}
+		if len(legacy.Children) > 1 {
+			value := normalizeLegacyNode(legacy.Children[1])
+			node.Value = &value
+		}
+	case "FunctionDefinition":
+		node.Visibility = legacy.stringAttribute("visibility")
+		node.StateMutability = legacyStateMutability(legacy)
+		var isConstructor bool
+		legacy.attribute("isConstructor", &isConstructor)
+		switch {
+		case isConstructor:
+			// Named after the contract, unlike the unnamed constructors of
+			// compact ASTs
+			node.Kind = "constructor"
+			node.Name = ""
+		case node.Name == "":
+			node.Kind = "fallback"
+		default:
+			node.Kind = "function"
+		}
+		node.Parameters, node.ReturnParameters = legacyParameterLists(legacy)
+		for _, child := range legacy.Children {
+			if child.Name == "ModifierInvocation" && len(child.Children) > 0 {
+				node.Modifiers = append(node.Modifiers, ModifierInvocation{
+					ID:           child.ID,
+					NodeType:     child.Name,
+					ModifierName: ASTNode{Name: child.Children[0].stringAttribute("value")},
+				})
+			}
+		}
+	case "ModifierDefinition":
+		node.Visibility = legacy.stringAttribute("visibility")
+		node.Parameters, _ = legacyParameterLists(legacy)
+	case "EventDefinition":
+		legacy.attribute("anonymous", &node.Anonymous)
+		node.Parameters, _ = legacyParameterLists(legacy)
+	case "StructDefinition", "EnumDefinition":
+		for _, child := range legacy.Children {
+			node.Members = append(node.Members, normalizeLegacyNode(child))
+		}
+	case "UsingForDirective":
+		if len(legacy.Children) > 0 {
+			node.LibraryName = normalizeLegacyTypeName(legacy.Children[0])
+		}
+		if len(legacy.Children) > 1 {
+			node.TypeName = normalizeLegacyTypeName(legacy.Children[1])
+		}
+	case "Literal":
+		node.Value = legacy.stringAttribute("value")
+		node.HexValue = legacy.stringAttribute("hexvalue")
+	case "Identifier":
+		node.Name = legacy.stringAttribute("value")
+	case "UnaryOperation":
+		node.Operator = legacy.stringAttribute("operator")
+		if len(legacy.Children) > 0 {
+			operand := normalizeLegacyNode(legacy.Children[0])
+			node.SubExpression = &operand
+		}
+	case "BinaryOperation":
+		node.Operator = legacy.stringAttribute("operator")
+		if len(legacy.Children) > 1 {
+			left := normalizeLegacyNode(legacy.Children[0])
+			right := normalizeLegacyNode(legacy.Children[1])
+			node.LeftExpression = &left
+			node.RightExpression = &right
+		}
+	case "FunctionCall":
+		// The called expression comes first, followed by the arguments
+		for i, child := range legacy.Children {
+			expression := normalizeLegacyNode(child)
+			if i == 0 {
+				node.Expression = &expression
+			} else {
+				node.Arguments = append(node.Arguments, &expression)
+			}
+		}
+	case "ElementaryTypeNameExpression":
+		node.NodeType = "Identifier"
+		node.Name = legacy.stringAttribute("value")
+	}
+	return node
+}
+
+// normalizeLegacyTypeName converts a legacy type name node.
+func normalizeLegacyTypeName(legacy LegacyASTNode) *TypeName {
+	typeName := &TypeName{
+		ID:              legacy.ID,
+		Src:             legacy.Src,
+		NodeType:        legacy.Name,
+		Name:            legacy.stringAttribute("name"),
+		StateMutability: legacy.stringAttribute("stateMutability"),
+		Visibility:      legacy.stringAttribute("visibility"),
+	}
+	legacy.attribute("referencedDeclaration", &typeName.ReferencedDeclaration)
+	if typeString := legacyTypeString(legacy.stringAttribute("type")); typeString != "" {
+		typeName.TypeDescriptions = &TypeDescriptions{TypeString: typeString}
+	}
+	switch legacy.Name {
+	case "Mapping":
+		if len(legacy.Children) > 1 {
+			typeName.KeyType = normalizeLegacyTypeName(legacy.Children[0])
+			typeName.ValueType = normalizeLegacyTypeName(legacy.Children[1])
+		}
+	case "ArrayTypeName":
+		if len(legacy.Children) > 0 {
+			typeName.BaseType = normalizeLegacyTypeName(legacy.Children[0])
+		}
+		if len(legacy.Children) > 1 {
+			length := normalizeLegacyNode(legacy.Children[1])
+			typeName.Length = &length
+		}
+	case "FunctionTypeName":
+		typeName.ParameterTypes, typeName.ReturnParameterTypes = legacyParameterLists(legacy)
+	}
+	return typeName
+}
+
+// legacyParameterLists returns the parameter lists among the children of a
+// function, modifier, event or function type: the parameters, then the
+// return parameters.
+func legacyParameterLists(legacy LegacyASTNode) (*ParameterList, *ParameterList) {
+	var lists []*ParameterList
+	for _, child := range legacy.Children {
+		if child.Name != "ParameterList" {
+			continue
+		}
+		list := &ParameterList{}
+		for _, parameter := range child.Children {
+			list.Parameters = append(list.Parameters, normalizeLegacyNode(parameter))
+		}
+		lists = append(lists, list)
+	}
+	switch len(lists) {
+	case 0:
+		return nil, nil
+	case 1:
+		return lists[0], nil
+	}
+	return lists[0], lists[1]
+}
+
+// legacyStateMutability derives the state mutability of a function, which
+// solc before 0.4.16 only reported through the constant and payable flags.
+func legacyStateMutability(legacy LegacyASTNode) string {
+	if mutability := legacy.stringAttribute("stateMutability"); mutability != "" {
+		return mutability
+	}
+	var constant, payable bool
+	legacy.attribute("constant", &constant)
+	legacy.attribute("payable", &payable)
+	switch {
+	case payable:
+		return "payable"
+	case constant:
+		return "view"
+	}
+	return "nonpayable"
+}
+
+// legacyTypeString removes the data location legacy type strings of
+// reference types end with, such as "storage pointer" or "memory".
+func legacyTypeString(typeString string) string {
+	for _, location := range []string{" storage pointer", " storage ref", " memory", " calldata"} {
+		typeString = strings.TrimSuffix(typeString, location)
+	}
+	return typeString
+}
+
+// attribute decodes an attribute into target, leaving it unchanged when the
+// attribute is missing or has another type.
+func (legacy LegacyASTNode) attribute(name string, target interface{}) {
+	if raw, ok := legacy.Attributes[name]; ok {
+		_ = json.Unmarshal(raw, target)
+	}
+}
+
+// stringAttribute returns a string attribute, or "" when it is not a string.
+func (legacy LegacyASTNode) stringAttribute(name string) string {
+	var value string
+	legacy.attribute(name, &value)
+	return value
+}
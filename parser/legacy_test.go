@@ -0,0 +1,164 @@
+// legacy_test.go
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadFixtures writes files into a data folder, keyed by path relative to it,
+// and loads the folder. Loading must not report any error or warning.
+func loadFixtures(t *testing.T, files map[string]string) map[string]*Contract {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	units, report, err := LoadSourceUnits(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Diagnostics.Failed() {
+		t.Fatalf("diagnostics: %v", report.Diagnostics.All())
+	}
+	return CollectContracts(units)
+}
+
+// legacyToken is the solc 0.4.24 AST of:
+//
+//	pragma solidity ^0.4.24;
+//	contract Owned { address public owner; }
+//	contract Token is Owned {
+//	    struct Account { uint balance; byte flag; }
+//	    mapping(address => uint) public balances;
+//	    Account[] public accounts;
+//	    event Transfer(address indexed to, uint value);
+//	    function Token() public {}
+//	    function transfer(address to, uint value) public returns (bool) {}
+//	    function balanceOf(address who) constant returns (uint) {}
+//	    function setFlags(byte[] flags, Account a) public {}
+//	    function () payable {}
+//	}
+//
+// balanceOf is written the pre-0.4.16 way, with the constant flag only.
+const legacyToken = `{"contractName": "Token", "ast": {"name": "SourceUnit", "id": 100, "attributes": {"absolutePath": "contracts/Token.sol"}, "children": [
+	{"id": 1, "name": "PragmaDirective", "attributes": {"literals": ["solidity", "^", "0.4", ".24"]}},
+	{"id": 10, "name": "ContractDefinition", "attributes": {"contractKind": "contract", "linearizedBaseContracts": [10], "name": "Owned"}, "children": [
+		{"id": 3, "name": "VariableDeclaration", "attributes": {"constant": false, "name": "owner", "stateVariable": true, "storageLocation": "default", "type": "address", "visibility": "public"}, "children": [
+			{"id": 2, "name": "ElementaryTypeName", "attributes": {"name": "address", "type": "address"}}]}]},
+	{"id": 90, "name": "ContractDefinition", "attributes": {"contractKind": "contract", "linearizedBaseContracts": [90, 10], "name": "Token"}, "children": [
+		{"id": 12, "name": "InheritanceSpecifier", "children": [
+			{"id": 11, "name": "UserDefinedTypeName", "attributes": {"name": "Owned", "referencedDeclaration": 10, "type": "contract Owned"}}]},
+		{"id": 17, "name": "StructDefinition", "attributes": {"canonicalName": "Token.Account", "name": "Account"}, "children": [
+			{"id": 14, "name": "VariableDeclaration", "attributes": {"constant": false, "name": "balance", "stateVariable": false, "storageLocation": "default", "type": "uint256", "visibility": "internal"}, "children": [
+				{"id": 13, "name": "ElementaryTypeName", "attributes": {"name": "uint", "type": "uint256"}}]},
+			{"id": 16, "name": "VariableDeclaration", "attributes": {"constant": false, "name": "flag", "stateVariable": false, "storageLocation": "default", "type": "bytes1", "visibility": "internal"}, "children": [
+				{"id": 15, "name": "ElementaryTypeName", "attributes": {"name": "byte", "type": "bytes1"}}]}]},
+		{"id": 22, "name": "VariableDeclaration", "attributes": {"constant": false, "name": "balances", "stateVariable": true, "storageLocation": "default", "type": "mapping(address => uint256)", "visibility": "public"}, "children": [
+			{"id": 21, "name": "Mapping", "attributes": {"type": "mapping(address => uint256)"}, "children": [
+				{"id": 19, "name": "ElementaryTypeName", "attributes": {"name": "address", "type": "address"}},
+				{"id": 20, "name": "ElementaryTypeName", "attributes": {"name": "uint", "type": "uint256"}}]}]},
+		{"id": 26, "name": "VariableDeclaration", "attributes": {"constant": false, "name": "accounts", "stateVariable": true, "storageLocation": "default", "type": "struct Token.Account storage ref[] storage ref", "visibility": "public"}, "children": [
+			{"id": 25, "name": "ArrayTypeName", "attributes": {"type": "struct Token.Account storage ref[] storage pointer"}, "children": [
+				{"id": 24, "name": "UserDefinedTypeName", "attributes": {"name": "Account", "referencedDeclaration": 17, "type": "struct Token.Account storage pointer"}}]}]},
+		{"id": 32, "name": "EventDefinition", "attributes": {"anonymous": false, "name": "Transfer"}, "children": [
+			{"id": 31, "name": "ParameterList", "children": [
+				{"id": 28, "name": "VariableDeclaration", "attributes": {"constant": false, "indexed": true, "name": "to", "stateVariable": false, "storageLocation": "default", "type": "address", "visibility": "internal"}, "children": [
+					{"id": 27, "name": "ElementaryTypeName", "attributes": {"name": "address", "type": "address"}}]},
+				{"id": 30, "name": "VariableDeclaration", "attributes": {"constant": false, "indexed": false, "name": "value", "stateVariable": false, "storageLocation": "default", "type": "uint256", "visibility": "internal"}, "children": [
+					{"id": 29, "name": "ElementaryTypeName", "attributes": {"name": "uint", "type": "uint256"}}]}]}]},
+		{"id": 36, "name": "FunctionDefinition", "attributes": {"constant": false, "isConstructor": true, "name": "Token", "payable": false, "stateMutability": "nonpayable", "visibility": "public"}, "children": [
+			{"id": 33, "name": "ParameterList", "children": []},
+			{"id": 34, "name": "ParameterList", "children": []},
+			{"id": 35, "name": "Block", "children": []}]},
+		{"id": 48, "name": "FunctionDefinition", "attributes": {"constant": false, "isConstructor": false, "name": "transfer", "payable": false, "stateMutability": "nonpayable", "visibility": "public"}, "children": [
+			{"id": 43, "name": "ParameterList", "children": [
+				{"id": 38, "name": "VariableDeclaration", "attributes": {"constant": false, "name": "to", "stateVariable": false, "storageLocation": "default", "type": "address", "visibility": "internal"}, "children": [
+					{"id": 37, "name": "ElementaryTypeName", "attributes": {"name": "address", "type": "address"}}]},
+				{"id": 40, "name": "VariableDeclaration", "attributes": {"constant": false, "name": "value", "stateVariable": false, "storageLocation": "default", "type": "uint256", "visibility": "internal"}, "children": [
+					{"id": 39, "name": "ElementaryTypeName", "attributes": {"name": "uint", "type": "uint256"}}]}]},
+			{"id": 46, "name": "ParameterList", "children": [
+				{"id": 45, "name": "VariableDeclaration", "attributes": {"constant": false, "name": "", "stateVariable": false, "storageLocation": "default", "type": "bool", "visibility": "internal"}, "children": [
+					{"id": 44, "name": "ElementaryTypeName", "attributes": {"name": "bool", "type": "bool"}}]}]},
+			{"id": 47, "name": "Block", "children": []}]},
+		{"id": 58, "name": "FunctionDefinition", "attributes": {"constant": true, "isConstructor": false, "name": "balanceOf", "payable": false, "visibility": "public"}, "children": [
+			{"id": 53, "name": "ParameterList", "children": [
+				{"id": 50, "name": "VariableDeclaration", "attributes": {"constant": false, "name": "who", "stateVariable": false, "storageLocation": "default", "type": "address", "visibility": "internal"}, "children": [
+					{"id": 49, "name": "ElementaryTypeName", "attributes": {"name": "address", "type": "address"}}]}]},
+			{"id": 56, "name": "ParameterList", "children": [
+				{"id": 55, "name": "VariableDeclaration", "attributes": {"constant": false, "name": "", "stateVariable": false, "storageLocation": "default", "type": "uint256", "visibility": "internal"}, "children": [
+					{"id": 54, "name": "ElementaryTypeName", "attributes": {"name": "uint", "type": "uint256"}}]}]},
+			{"id": 57, "name": "Block", "children": []}]},
+		{"id": 70, "name": "FunctionDefinition", "attributes": {"constant": false, "isConstructor": false, "name": "setFlags", "payable": false, "stateMutability": "nonpayable", "visibility": "public"}, "children": [
+			{"id": 66, "name": "ParameterList", "children": [
+				{"id": 62, "name": "VariableDeclaration", "attributes": {"constant": false, "name": "flags", "stateVariable": false, "storageLocation": "default", "type": "bytes1[] memory", "visibility": "internal"}, "children": [
+					{"id": 61, "name": "ArrayTypeName", "attributes": {"type": "bytes1[] storage pointer"}, "children": [
+						{"id": 60, "name": "ElementaryTypeName", "attributes": {"name": "byte", "type": "bytes1"}}]}]},
+				{"id": 65, "name": "VariableDeclaration", "attributes": {"constant": false, "name": "a", "stateVariable": false, "storageLocation": "default", "type": "struct Token.Account memory", "visibility": "internal"}, "children": [
+					{"id": 64, "name": "UserDefinedTypeName", "attributes": {"name": "Account", "referencedDeclaration": 17, "type": "struct Token.Account storage pointer"}}]}]},
+			{"id": 67, "name": "ParameterList", "children": []},
+			{"id": 68, "name": "Block", "children": []}]},
+		{"id": 80, "name": "FunctionDefinition", "attributes": {"constant": false, "isConstructor": false, "name": "", "payable": true, "visibility": "public"}, "children": [
+			{"id": 77, "name": "ParameterList", "children": []},
+			{"id": 78, "name": "ParameterList", "children": []},
+			{"id": 79, "name": "Block", "children": []}]}]}]}}`
+
+func TestLegacyAST(t *testing.T) {
+	tests := []struct {
+		name     string
+		contract string
+		want     []string
+	}{
+		{
+			name:     "base",
+			contract: "Owned",
+			want: []string{
+				"contract Owned",
+				"getter owner() 8da5cb5b",
+				"variable address public owner",
+			},
+		},
+		{
+			name:     "derived",
+			contract: "Token",
+			want: []string{
+				"contract Token is Owned",
+				"constructor () nonpayable",
+				"fallback () payable",
+				"function transfer(address,uint256) public nonpayable a9059cbb",
+				"function balanceOf(address) public view 70a08231",
+				"function setFlags(bytes1[],(uint256,bytes1)) public nonpayable 310323ad",
+				"getter accounts(uint256) f2a40db8",
+				"getter balances(address) 27e235e3",
+				"event Transfer(address,uint256)",
+				"variable struct Token.Account[] public accounts",
+				"mapping mapping(address => uint) balances",
+				"struct Account {uint balance; byte flag}",
+			},
+		},
+	}
+	contracts := loadFixtures(t, map[string]string{"Token.json": legacyToken})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract, err := LookupContract(contracts, test.contract)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contract.ASTFlavour != ASTLegacy {
+				t.Errorf("flavour = %s, want %s", contract.ASTFlavour, ASTLegacy)
+			}
+			got := declarationRows(contract)
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("declarations =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// lexer.go
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Token kinds of the Solidity lexer.
+const (
+	TokenIdentifier = "identifier"
+	TokenNumber     = "number"
+	TokenString     = "string"
+	TokenPunct      = "punctuation"
+	TokenEOF        = "end of file"
+)
+
+// Token is a lexical token of a Solidity source file.
+type Token struct {
+	Kind  string
+	Text  string
+	Start int // Byte offset of the first character
+	End   int // Byte offset following the last character
+	Line  int
+	Col   int
+}
+
+// operators lists the multi-character operators, longest first so that the
+// lexer matches the longest one.
+var operators = []string{
+	">>>=",
+	">>>", "<<=", ">>=", "...",
+	"=>", "==", "!=", "<=", ">=", "&&", "||", "++", "--", "+=", "-=", "*=", "/=",
+	"%=", "|=", "&=", "^=", "<<", ">>", "**", "->", ":=",
+}
+
+// Tokenize splits Solidity source code into tokens, dropping whitespace and
+// comments. The last token is always TokenEOF.
+func Tokenize(src string) ([]Token, error) {
+	var tokens []Token
+	line, lineStart := 1, 0
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == '\n':
+			i++
+			line, lineStart = line+1, i
+			continue
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			i++
+			continue
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("%d:%d: unterminated comment", line, i-lineStart+1)
+			}
+			comment := src[i : i+2+end+2]
+			line += strings.Count(comment, "\n")
+			if n := strings.LastIndex(comment, "\n"); n >= 0 {
+				lineStart = i + n + 1
+			}
+			i += len(comment)
+			continue
+		}
+
+		token := Token{Start: i, Line: line, Col: i - lineStart + 1}
+		switch {
+		case isIdentifierStart(c):
+			token.Kind = TokenIdentifier
+			for i < len(src) && (isIdentifierStart(src[i]) || isDigit(src[i])) {
+				i++
+			}
+		case isDigit(c) || (c == '.' && i+1 < len(src) && isDigit(src[i+1])):
+			// Decimal, hexadecimal and scientific literals, with underscores
+			token.Kind = TokenNumber
+			for i < len(src) && (isIdentifierStart(src[i]) || isDigit(src[i]) || src[i] == '.') {
+				i++
+			}
+		case c == '"' || c == '\'':
+			token.Kind = TokenString
+			i++
+			for i < len(src) && src[i] != c {
+				if src[i] == '\\' {
+					i++
+				}
+				if i < len(src) && src[i] == '\n' {
+					return nil, fmt.Errorf("%d:%d: unterminated string", token.Line, token.Col)
+				}
+				i++
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("%d:%d: unterminated string", token.Line, token.Col)
+			}
+			i++
+		default:
+			token.Kind = TokenPunct
+			length := 1
+			for _, operator := range operators {
+				if strings.HasPrefix(src[i:], operator) {
+					length = len(operator)
+					break
+				}
+			}
+			i += length
+		}
+		token.End = i
+		token.Text = src[token.Start:token.End]
+		tokens = append(tokens, token)
+	}
+	tokens = append(tokens, Token{Kind: TokenEOF, Start: len(src), End: len(src), Line: line, Col: len(src) - lineStart + 1})
+	return tokens, nil
+}
+
+// isIdentifierStart reports whether c may start an identifier.
+func isIdentifierStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isDigit reports whether c is a decimal digit.
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
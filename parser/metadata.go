@@ -0,0 +1,487 @@
+// metadata.go
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Origins of Metadata.
+const (
+	OriginSourcify  = "Sourcify"
+	OriginEtherscan = "Etherscan"
+)
+
+// Metadata describes how a verified contract was compiled, as read from a
+// Sourcify metadata file or an Etherscan verified-source response.
+type Metadata struct {
+	Origin          string // Sourcify or Etherscan
+	Path            string // File the metadata was read from
+	ChainID         string // From the Sourcify folder layout, empty otherwise
+	Address         string // From the Sourcify folder layout, empty otherwise
+	CompilerVersion string
+	Language        string
+	EVMVersion      string
+	Optimizer       bool
+	Runs            int
+	ViaIR           bool
+	Remappings      []string
+	Libraries       map[string]string // Library address by library name
+	Sources         []MetadataSource
+}
+
+// MetadataSource is a source file of a verified compilation.
+type MetadataSource struct {
+	Path      string
+	Keccak256 string
+	License   string
+	URLs      []string
+	Available bool   // Whether the content was found in the bundle
+	Error     string // Reason the content could not be parsed
+}
+
+// Settings summarizes the compiler settings, such as
+// "optimizer 200 runs, EVM paris, via IR".
+func (m *Metadata) Settings() string {
+	var settings []string
+	if m.Optimizer {
+		settings = append(settings, fmt.Sprintf("optimizer %d runs", m.Runs))
+	} else {
+		settings = append(settings, "optimizer disabled")
+	}
+	if m.EVMVersion != "" {
+		settings = append(settings, "EVM "+m.EVMVersion)
+	}
+	if m.ViaIR {
+		settings = append(settings, "via IR")
+	}
+	return strings.Join(settings, ", ")
+}
+
+// MetadataBundle is a verified contract read from disk: its metadata, the ABI
+// and the source units parsed from the source files that came with it.
+type MetadataBundle struct {
+	Metadata   *Metadata
+	Target     string // Name of the verified contract
+	TargetPath string // Source path of the verified contract, empty when unknown
+	ABI        json.RawMessage
+	Units      []*SourceUnit
+}
+
+// SourcifyMetadata is the metadata.json file solc embeds the hash of in the
+// bytecode, and Sourcify stores next to a sources/ folder.
+type SourcifyMetadata struct {
+	Compiler struct {
+		Version string `json:"version"`
+	} `json:"compiler"`
+	Language string `json:"language"`
+	Output   struct {
+		ABI json.RawMessage `json:"abi"`
+	} `json:"output"`
+	Settings MetadataSettings                  `json:"settings"`
+	Sources  map[string]SourcifyMetadataSource `json:"sources"`
+}
+
+// SourcifyMetadataSource is a source file listed in a metadata file.
+type SourcifyMetadataSource struct {
+	Keccak256 string   `json:"keccak256"`
+	License   string   `json:"license"`
+	URLs      []string `json:"urls"`
+	Content   *string  `json:"content"`
+}
+
+// MetadataSettings holds the compiler settings of a metadata file or of a
+// standard-JSON input.
+type MetadataSettings struct {
+	CompilationTarget map[string]string `json:"compilationTarget"` // Contract name by source path, metadata only
+	EVMVersion        string            `json:"evmVersion"`
+	Optimizer         struct {
+		Enabled bool `json:"enabled"`
+		Runs    int  `json:"runs"`
+	} `json:"optimizer"`
+	ViaIR      bool            `json:"viaIR"`
+	Remappings []string        `json:"remappings"`
+	Libraries  json.RawMessage `json:"libraries"`
+}
+
+// EtherscanSource is an entry of the result of the Etherscan getsourcecode
+// API.
+type EtherscanSource struct {
+	SourceCode       string `json:"SourceCode"`
+	ABI              string `json:"ABI"`
+	ContractName     string `json:"ContractName"`
+	CompilerVersion  string `json:"CompilerVersion"`
+	OptimizationUsed string `json:"OptimizationUsed"`
+	Runs             string `json:"Runs"`
+	EVMVersion       string `json:"EVMVersion"`
+	Library          string `json:"Library"`
+	LicenseType      string `json:"LicenseType"`
+}
+
+// standardJSONInput is the standard-JSON input Etherscan stores as the source
+// code of contracts verified with it.
+type standardJSONInput struct {
+	Language string `json:"language"`
+	Sources  map[string]struct {
+		Content string `json:"content"`
+	} `json:"sources"`
+	Settings MetadataSettings `json:"settings"`
+}
+
+// ParseMetadataFile reads a Sourcify metadata file or a saved Etherscan
+// verified-source response, with the source files that come with it.
+func ParseMetadataFile(path string) (*MetadataBundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseMetadataBundle(path, data, SniffFormat(data), nil)
+}
+
+// parseMetadataBundle reads a metadata file of the given format.
+func parseMetadataBundle(path string, data []byte, format FileFormat, diags *Diagnostics) (*MetadataBundle, error) {
+	switch format {
+	case FormatSourcify:
+		var metadata SourcifyMetadata
+		if err := json.Unmarshal(data, &metadata); err != nil {
+			return nil, fmt.Errorf("failed to parse metadata %s: %w", path, err)
+		}
+		return sourcifyBundle(path, metadata, diags)
+	case FormatEtherscan:
+		source, err := decodeEtherscanSource(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse verified source %s: %w", path, err)
+		}
+		return etherscanBundle(path, source, diags)
+	}
+	return nil, fmt.Errorf("%s is not a metadata file", path)
+}
+
+// sourcifyBundle reads the sources of a metadata file, from the content it
+// embeds or from the sources/ folder next to it.
+func sourcifyBundle(metadataPath string, metadata SourcifyMetadata, diags *Diagnostics) (*MetadataBundle, error) {
+	bundle := &MetadataBundle{
+		Metadata: &Metadata{
+			Origin:          OriginSourcify,
+			Path:            metadataPath,
+			CompilerVersion: metadata.Compiler.Version,
+			Language:        metadata.Language,
+		},
+		ABI: metadata.Output.ABI,
+	}
+	applySettings(bundle.Metadata, metadata.Settings)
+	for sourcePath, name := range metadata.Settings.CompilationTarget {
+		bundle.TargetPath, bundle.Target = sourcePath, name
+	}
+
+	// Sourcify stores matches under <match>/<chainId>/<address>/metadata.json
+	dir := filepath.Dir(metadataPath)
+	if address := filepath.Base(dir); strings.HasPrefix(address, "0x") {
+		bundle.Metadata.Address = address
+		bundle.Metadata.ChainID = filepath.Base(filepath.Dir(dir))
+	}
+
+	contents := make(map[string]string)
+	for sourcePath, source := range metadata.Sources {
+		bundle.Metadata.Sources = append(bundle.Metadata.Sources, MetadataSource{
+			Path:      sourcePath,
+			Keccak256: source.Keccak256,
+			License:   source.License,
+			URLs:      source.URLs,
+		})
+		if source.Content != nil {
+			contents[sourcePath] = *source.Content
+			continue
+		}
+		cleaned := path.Clean("/" + sourcePath)
+		data, err := os.ReadFile(filepath.Join(dir, "sources", filepath.FromSlash(cleaned)))
+		if err == nil {
+			contents[sourcePath] = string(data)
+		} else {
+			diags.Infof(0, "", "content of source %s not found", sourcePath)
+		}
+	}
+	parseBundleSources(bundle, contents, diags)
+	return bundle, nil
+}
+
+// decodeEtherscanSource decodes a getsourcecode response, its result array or
+// a single result entry.
+func decodeEtherscanSource(data []byte) (EtherscanSource, error) {
+	var response struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if json.Unmarshal(data, &response) == nil && isPresent(response.Result) {
+		data = response.Result
+	}
+	var entries []EtherscanSource
+	if json.Unmarshal(data, &entries) == nil {
+		if len(entries) == 0 {
+			return EtherscanSource{}, fmt.Errorf("empty result")
+		}
+		return entries[0], nil
+	}
+	var entry EtherscanSource
+	err := json.Unmarshal(data, &entry)
+	return entry, err
+}
+
+// etherscanBundle reads the sources of an Etherscan verified-source entry. The
+// source code is either a single file, a JSON object of files, or a
+// standard-JSON input wrapped in an extra pair of braces.
+func etherscanBundle(path string, source EtherscanSource, diags *Diagnostics) (*MetadataBundle, error) {
+	if source.SourceCode == "" {
+		return nil, fmt.Errorf("contract source code not verified")
+	}
+	bundle := &MetadataBundle{
+		Metadata: &Metadata{
+			Origin:          OriginEtherscan,
+			Path:            path,
+			CompilerVersion: strings.TrimPrefix(source.CompilerVersion, "v"),
+			Language:        "Solidity",
+			EVMVersion:      source.EVMVersion,
+			Optimizer:       source.OptimizationUsed == "1",
+			Libraries:       etherscanLibraries(source.Library),
+		},
+		Target: source.ContractName,
+	}
+	if strings.HasPrefix(source.CompilerVersion, "vyper") {
+		bundle.Metadata.Language = "Vyper"
+		bundle.Metadata.CompilerVersion = strings.TrimPrefix(source.CompilerVersion, "vyper:")
+	}
+	if strings.EqualFold(bundle.Metadata.EVMVersion, "default") {
+		bundle.Metadata.EVMVersion = ""
+	}
+	bundle.Metadata.Runs, _ = strconv.Atoi(source.Runs)
+	// The ABI is an error message when the contract is not verified
+	if strings.HasPrefix(strings.TrimSpace(source.ABI), "[") {
+		bundle.ABI = json.RawMessage(source.ABI)
+	}
+
+	contents := make(map[string]string)
+	code := strings.TrimSpace(source.SourceCode)
+	var files map[string]struct {
+		Content string `json:"content"`
+	}
+	switch {
+	case strings.HasPrefix(code, "{{") && strings.HasSuffix(code, "}}"):
+		var input standardJSONInput
+		if err := json.Unmarshal([]byte(code[1:len(code)-1]), &input); err != nil {
+			return nil, fmt.Errorf("invalid standard-JSON input: %w", err)
+		}
+		for sourcePath, file := range input.Sources {
+			contents[sourcePath] = file.Content
+		}
+		if input.Language != "" {
+			bundle.Metadata.Language = input.Language
+		}
+		applySettings(bundle.Metadata, input.Settings)
+	case strings.HasPrefix(code, "{") && json.Unmarshal([]byte(code), &files) == nil:
+		for sourcePath, file := range files {
+			contents[sourcePath] = file.Content
+		}
+	default:
+		extension := ".sol"
+		if bundle.Metadata.Language == "Vyper" {
+			extension = ".vy"
+		}
+		bundle.TargetPath = source.ContractName + extension
+		contents[bundle.TargetPath] = source.SourceCode
+	}
+	for sourcePath := range contents {
+		bundle.Metadata.Sources = append(bundle.Metadata.Sources, MetadataSource{Path: sourcePath, License: source.LicenseType})
+	}
+	parseBundleSources(bundle, contents, diags)
+	return bundle, nil
+}
+
+// applySettings copies the compiler settings of a metadata file or
+// standard-JSON input.
+func applySettings(metadata *Metadata, settings MetadataSettings) {
+	metadata.EVMVersion = settings.EVMVersion
+	metadata.Optimizer = settings.Optimizer.Enabled
+	metadata.Runs = settings.Optimizer.Runs
+	metadata.ViaIR = settings.ViaIR
+	metadata.Remappings = settings.Remappings
+	if libraries := decodeLibraries(settings.Libraries); len(libraries) > 0 {
+		metadata.Libraries = libraries
+	}
+}
+
+// decodeLibraries reads library addresses, keyed "path:Name" in metadata files
+// and by path then name in standard-JSON inputs.
+func decodeLibraries(raw json.RawMessage) map[string]string {
+	if !isPresent(raw) {
+		return nil
+	}
+	libraries := make(map[string]string)
+	var flat map[string]string
+	if json.Unmarshal(raw, &flat) == nil {
+		for key, address := range flat {
+			libraries[key[strings.LastIndex(key, ":")+1:]] = address
+		}
+		return libraries
+	}
+	var nested map[string]map[string]string
+	if json.Unmarshal(raw, &nested) == nil {
+		for _, byName := range nested {
+			for name, address := range byName {
+				libraries[name] = address
+			}
+		}
+	}
+	return libraries
+}
+
+// etherscanLibraries reads the Library field, such as "Lib1:0x12…;Lib2:0x34…".
+func etherscanLibraries(field string) map[string]string {
+	if field == "" {
+		return nil
+	}
+	libraries := make(map[string]string)
+	for _, library := range strings.Split(field, ";") {
+		if name, address, ok := strings.Cut(library, ":"); ok {
+			libraries[strings.TrimSpace(name)] = strings.TrimSpace(address)
+		}
+	}
+	return libraries
+}
+
+// parseBundleSources parses the Solidity sources of a bundle. Sources that fail
+// to parse are left out and the error is recorded on the source, so that the
+// rest of the bundle can still be browsed.
+func parseBundleSources(bundle *MetadataBundle, contents map[string]string, diags *Diagnostics) {
+	sort.Slice(bundle.Metadata.Sources, func(i, j int) bool {
+		return bundle.Metadata.Sources[i].Path < bundle.Metadata.Sources[j].Path
+	})
+	for i := range bundle.Metadata.Sources {
+		source := &bundle.Metadata.Sources[i]
+		content, ok := contents[source.Path]
+		if !ok {
+			continue
+		}
+		source.Available = true
+		if bundle.Metadata.Language != "Solidity" {
+			continue
+		}
+		ast, err := ParseSolidity(source.Path, content)
+		if err == nil {
+			var unit *SourceUnit
+			unit, err = ExtractSourceUnit(ast, diags)
+			if err == nil {
+				bundle.Units = append(bundle.Units, compiledIn([]*SourceUnit{unit}, bundle.Metadata.Path)...)
+			}
+		}
+		if err != nil {
+			source.Error = err.Error()
+			diags.Warnf(0, "", "source %s not parsed: %v", source.Path, err)
+		}
+	}
+}
+
+// addBundle adds a bundle to the source units. When the project declares the
+// verified contract, through compiler output or a Solidity file, the metadata
+// is attached to it and only the bundle sources the project lacks are added.
+// Otherwise the metadata goes to the contract parsed from the bundle, and the
+// bundle units whose path is already taken, e.g. by the same contract verified
+// on another chain, are kept apart under a path qualified with the bundle.
+func addBundle(units map[string]*SourceUnit, project map[string]*SourceUnit, bundle *MetadataBundle, diags *Diagnostics) {
+	err := attachMetadata(project, bundle, diags)
+	paired := err == nil
+	if !paired && len(bundle.Units) > 0 {
+		own := make(map[string]*SourceUnit)
+		for _, unit := range bundle.Units {
+			own[unit.Path] = unit
+		}
+		err = attachMetadata(own, bundle, diags)
+	}
+	if !paired && err != nil {
+		// A bundle whose contract is missing still has its sources browsable
+		diags.Warnf(0, "", "%v", err)
+	}
+	for _, unit := range bundle.Units {
+		if _, ok := units[unit.Path]; ok {
+			if paired {
+				continue
+			}
+			qualifyPath(unit, bundle.label())
+		}
+		if _, ok := units[unit.Path]; ok {
+			diags.Warnf(0, "", "source %s is loaded twice, the copy from this bundle is left out", unit.Path)
+			continue
+		}
+		units[unit.Path] = unit
+	}
+}
+
+// label names a bundle by the chain and address of its Sourcify match, or by
+// the name of the file it was read from.
+func (bundle *MetadataBundle) label() string {
+	if bundle.Metadata.Address != "" {
+		return bundle.Metadata.ChainID + "/" + bundle.Metadata.Address
+	}
+	name := filepath.Base(bundle.Metadata.Path)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// qualifyPath prefixes the path of a source unit, and of its contracts, with
+// the label of the bundle it comes from.
+func qualifyPath(unit *SourceUnit, label string) {
+	unit.Path = label + "/" + unit.Path
+	for _, contract := range unit.Contracts {
+		contract.SourcePath = unit.Path
+	}
+}
+
+// attachMetadata pairs a bundle with its verified contract, parsed from the
+// bundle sources or from compiler output found in the data folder. The ABI of
+// the bundle is used when the contract has none, an invalid one is reported to
+// diags. A contract already paired with another bundle is left to it.
+func attachMetadata(units map[string]*SourceUnit, bundle *MetadataBundle, diags *Diagnostics) error {
+	var candidates []*SourceUnit
+	if unit, ok := units[bundle.TargetPath]; ok {
+		candidates = append(candidates, unit)
+	} else {
+		for _, source := range bundle.Metadata.Sources {
+			if unit, ok := units[source.Path]; ok {
+				candidates = append(candidates, unit)
+			}
+		}
+	}
+	for _, unit := range candidates {
+		for _, contract := range unit.Contracts {
+			if contract.Name != bundle.Target {
+				continue
+			}
+			if contract.Metadata != nil && contract.Metadata != bundle.Metadata {
+				return fmt.Errorf("verified contract %s is already paired with %s", bundle.Target, contract.Metadata.Path)
+			}
+			contract.Metadata = bundle.Metadata
+			if contract.ABI == nil && len(bundle.ABI) > 0 {
+				var err error
+				contract.ABI, err = DecodeABI(bundle.ABI)
+				if err != nil {
+					diags.Errorf(contract.ID, "", "invalid ABI of %s: %v", bundle.Target, err)
+				}
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("verified contract %s not found in its sources", bundle.Target)
+}
+
+// isMetadataSources reports whether a directory is the sources/ folder of a
+// Sourcify match, whose files are read with the metadata file next to it.
+func isMetadataSources(dir string) bool {
+	if filepath.Base(dir) != "sources" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(filepath.Dir(dir), "metadata.json"))
+	return err == nil
+}
@@ -0,0 +1,115 @@
+// metadata_test.go
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// sourcifyToken is a Sourcify full match of Token, whose sources are stored
+// next to the metadata file.
+var sourcifyToken = map[string]string{
+	"full_match/1/0x00000000000000000000000000000000000000aa/metadata.json": `{
+		"compiler": {"version": "0.8.20+commit.a1b79de6"},
+		"language": "Solidity",
+		"output": {"abi": [
+			{"type": "function", "name": "owner", "inputs": [], "outputs": [{"name": "", "type": "address"}], "stateMutability": "view"},
+			{"type": "function", "name": "balanceOf", "inputs": [{"name": "", "type": "address"}], "outputs": [{"name": "", "type": "uint256"}], "stateMutability": "view"},
+			{"type": "function", "name": "transfer", "inputs": [{"name": "to", "type": "address"}, {"name": "amount", "type": "uint256"}], "outputs": [{"name": "", "type": "bool"}], "stateMutability": "nonpayable"}
+		]},
+		"settings": {
+			"compilationTarget": {"src/Token.sol": "Token"},
+			"evmVersion": "paris",
+			"optimizer": {"enabled": true, "runs": 200},
+			"remappings": []
+		},
+		"sources": {
+			"src/Token.sol": {"keccak256": "0xaa", "license": "MIT", "urls": []},
+			"src/Ownable.sol": {"keccak256": "0xbb", "license": "MIT", "urls": []}
+		},
+		"version": 1
+	}`,
+	"full_match/1/0x00000000000000000000000000000000000000aa/sources/src/Token.sol": `pragma solidity ^0.8.20;
+		import "./Ownable.sol";
+		contract Token is Ownable {
+			mapping(address => uint256) public balanceOf;
+			function transfer(address to, uint256 amount) external returns (bool) {}
+		}`,
+	"full_match/1/0x00000000000000000000000000000000000000aa/sources/src/Ownable.sol": `pragma solidity ^0.8.20;
+		abstract contract Ownable { address public owner; }`,
+}
+
+// etherscanVault is an Etherscan getsourcecode response of Vault, verified
+// with a standard-JSON input.
+var etherscanVault = map[string]string{
+	"Vault.json": `{"status": "1", "message": "OK", "result": [{
+		"SourceCode": "{{\"language\": \"Solidity\", \"sources\": {\"contracts/Vault.sol\": {\"content\": \"pragma solidity 0.8.24;\\nimport './Base.sol';\\ncontract Vault is Base { struct S { uint a; } S public s; function deposit(S calldata x) external payable {} }\"}, \"contracts/Base.sol\": {\"content\": \"pragma solidity 0.8.24;\\nabstract contract Base { uint256 internal total; }\"}}, \"settings\": {\"optimizer\": {\"enabled\": true, \"runs\": 1000}, \"evmVersion\": \"cancun\", \"viaIR\": true}}}",
+		"ABI": "Contract source code not verified",
+		"ContractName": "Vault",
+		"CompilerVersion": "v0.8.24+commit.e11b9ed9",
+		"OptimizationUsed": "1",
+		"Runs": "1000",
+		"EVMVersion": "cancun",
+		"Library": "",
+		"LicenseType": "MIT"
+	}]}`,
+}
+
+func TestMetadataBundles(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        map[string]string
+		contract     string
+		wantMetadata string
+		want         []string
+	}{
+		{
+			name:         "Sourcify",
+			files:        sourcifyToken,
+			contract:     "Token",
+			wantMetadata: "Sourcify 1/0x00000000000000000000000000000000000000aa Solidity 0.8.20+commit.a1b79de6, optimizer 200 runs, EVM paris",
+			want: []string{
+				"contract Token is Ownable",
+				"function transfer(address,uint256) external nonpayable a9059cbb",
+				"getter balanceOf(address) 70a08231",
+				"mapping mapping(address => uint256) balanceOf",
+			},
+		},
+		{
+			name:         "Etherscan",
+			files:        etherscanVault,
+			contract:     "Vault",
+			wantMetadata: "Etherscan / Solidity 0.8.24+commit.e11b9ed9, optimizer 1000 runs, EVM cancun, via IR",
+			want: []string{
+				"contract Vault is Base",
+				"function deposit((uint256)) external payable d1e92c11",
+				"getter s() 86b714e2",
+				"variable S public s",
+				"struct S {uint a}",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contracts := loadFixtures(t, test.files)
+			contract, err := LookupContract(contracts, test.contract)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contract.Metadata == nil {
+				t.Fatal("metadata not attached")
+			}
+			metadata := contract.Metadata
+			got := fmt.Sprintf("%s %s/%s %s %s, %s", metadata.Origin, metadata.ChainID, metadata.Address,
+				metadata.Language, metadata.CompilerVersion, metadata.Settings())
+			if got != test.wantMetadata {
+				t.Errorf("metadata = %q, want %q", got, test.wantMetadata)
+			}
+			rows := declarationRows(contract)
+			if strings.Join(rows, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("declarations =\n%s\nwant\n%s", strings.Join(rows, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
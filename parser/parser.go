@@ -6,24 +6,68 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
 )
 
 // Contract represents a smart contract with all its components.
 type Contract struct {
+	ID          int
 	Name        string
+	SourcePath  string
+	Kind        string // contract, interface or library
+	Abstract    bool
 	Pragma      string
 	Imports     []Import
 	Inherits    []string
+	// LinearizedBaseContracts holds the C3 linearization as AST node IDs, starting with the contract itself
+	LinearizedBaseContracts []int
+	Linearized              []string // LinearizedBaseContracts resolved to contract names
+	InterfaceID             string   // EIP-165 identifier, only set for interfaces
+	ExternalInterfaceID     string   // XOR of the selectors of the whole external surface
 	Constructor *Function
+	Fallback    *Function
+	Receive     *Function
 	Variables   []Variable
 	Constants   []Variable
 	Functions   []Function
 	Events      []Event
+	Errors      []Error
 	Modifiers   []Modifier
 	Structs     []Struct
 	Enums       []Enum
+	ValueTypes  []UserDefinedValueType
+	UsingFor    []UsingFor
 	Mappings 		[]Variable
+	Getters     []Function // Compiler-generated getters of public state variables
+	CompilerStorage []StorageEntry // Storage layout reported by solc, nil when the artifact has none
+	ABI             *abi.ABI       // ABI of the artifact the contract was built into, nil when the artifact has none
+	ASTFlavour      string         // compact, legacy for solc 0.4 ASTs, vyper, or source when parsed from Solidity source
+	Metadata        *Metadata      // Compilation the contract was verified with, nil without a metadata file
+	Compilation     string         // Build-info, standard-JSON or metadata file the contract was compiled in, empty otherwise
+	aliases         []declarationKey // Keys of the copies of the contract merged in from other compilations
+}
+
+// SourceUnit represents a parsed source file: its contracts and the
+// declarations made at file level, outside of any contract.
+type SourceUnit struct {
+	Path      string
+	Pragma    string
+	Imports   []Import
+	Contracts []*Contract
+	Functions []Function // Free functions
+	Constants []Variable
+	Events    []Event // File-level events, since solc 0.8.22
+	Errors    []Error
+	Structs   []Struct
+	Enums     []Enum
+	ValueTypes []UserDefinedValueType
+	UsingFor  []UsingFor
+	ASTFlavour string // Flavour of the AST the unit was extracted from, as on Contract
+	Compilation string // Build-info, standard-JSON or metadata file the unit was compiled in, empty otherwise
+	aliases   []*SourceUnit // Copies of the unit from other compilations, merged into this one
 }
 
 // Import represents an import directive in Solidity.
@@ -35,6 +79,7 @@ type Import struct {
 
 // Variable represents a state variable declaration.
 type Variable struct {
+	ID               int // AST node ID
 	Name             string
 	Type             string
 	Visibility       string
@@ -44,11 +89,17 @@ type Variable struct {
 	Mutability       string // For 'immutable' variables
 	FunctionSelector string // For variables with selectors
 	Value            string
+	DeclaredIn       string // Name of the contract declaring the variable
+	Overridden       bool   // Set in the effective view when a derived contract redeclares it
+	UnderlyingType   string // For variables of a user-defined value type
+	TypeInfo         *Type  // Structured form of Type
 }
 
 // Function represents a function definition.
 type Function struct {
+	ID               int // AST node ID
 	Name             string
+	Signature        string // Canonical signature, e.g. transfer(address,uint256)
 	Visibility       string
 	Kind             string // For 'constructor' functions
 	StateMutability  string
@@ -57,28 +108,54 @@ type Function struct {
 	Modifiers        []string
 	BaseFunctions    []int      // IDs of base functions
 	Overrides        []string   // Names of contracts being overridden
+	Selector         string     // 4-byte selector of public and external functions, hex encoded
+	Getter           bool       // Set for the getter of a public state variable
+	selectorFromAST  bool
+	DeclaredIn       string     // Name of the contract declaring the function
+	Overridden       bool       // Set in the effective view when a derived contract redeclares it
 }
 
 // Event represents an event definition.
 type Event struct {
+	ID         				int
+	Name       				string
+	Parameters 				[]Parameter
+	Signature  				string
+	Topic0     				string // keccak256(Signature), hex encoded; empty for anonymous events
+	Anonymous  				bool
+	Warning    				string // Validation problem, e.g. too many indexed parameters
+	DeclaredIn 				string
+}
+
+// Error represents a custom error definition.
+type Error struct {
+	ID         				int
 	Name       				string
 	Parameters 				[]Parameter
+	Signature  				string // Canonical signature, e.g. Unauthorized(address)
+	Selector   				string // First 4 bytes of keccak256(Signature), hex encoded
+	DeclaredIn 				string
 }
 
 // Modifier represents a function modifier.
 type Modifier struct {
+	ID         				int
 	Name       				string
 	Parameters 				[]Parameter
+	DeclaredIn 				string
+	Overridden 				bool
 }
 
 // Struct represents a struct definition.
 type Struct struct {
+	ID      					int
 	Name    					string
 	Members 					[]Variable
 }
 
 // Enum represents an enum definition.
 type Enum struct {
+	ID     						int
 	Name   						string
 	Values 						[]string
 }
@@ -88,27 +165,55 @@ type Parameter struct {
 	Name 							string
 	Type 							string
 	Indexed 					bool // For event parameters
+	UnderlyingType 		string // For parameters of a user-defined value type
+	TypeInfo 					*Type  // Structured form of Type
+}
+
+// UserDefinedValueType represents a `type Name is underlying;` definition.
+type UserDefinedValueType struct {
+	ID             		int
+	Name           		string
+	UnderlyingType 		string
+	DeclaredIn     		string
+	UnderlyingTypeInfo *Type
+}
+
+// UsingFor represents a `using ... for ...;` directive.
+type UsingFor struct {
+	ID        				int
+	Library   				string   // Empty when a function list is used
+	Functions 				[]string // Attached functions, with the operator they bind if any
+	TypeName  				string   // "*" when attached to all types
+	Global    				bool
 }
 
 // ABIFile represents the structure of the ABI JSON file including the AST.
 type ABIFile struct {
 	ContractName 		string      `json:"contractName,omitempty"`
 	AST          		AST         `json:"ast,omitempty"`
+	StorageLayout		*StorageLayout `json:"storageLayout,omitempty"`
+	ABI          		json.RawMessage `json:"abi,omitempty"`
 }
 
 // AST represents the Abstract Syntax Tree of the contract.
 type AST struct {
+	AbsolutePath 	string 					`json:"absolutePath,omitempty"`
 	Nodes 				[]ASTNode 			`json:"nodes"`
+	Flavour 			string 					`json:"-"` // compact, legacy or vyper
 }
 
 // ASTNode represents a node in the AST.
 type ASTNode struct {
 	ID                     int               `json:"id"`
+	Src                    string            `json:"src,omitempty"`
 	NodeType               string            `json:"nodeType"`
 	Name                   string            `json:"name,omitempty"`
 	AbsolutePath           string            `json:"absolutePath,omitempty"`
 	File                   string            `json:"file,omitempty"`
 	BaseContracts          []BaseContract    `json:"baseContracts,omitempty"`
+	ContractKind           string            `json:"contractKind,omitempty"`
+	Abstract               bool              `json:"abstract,omitempty"`
+	LinearizedBaseContracts []int            `json:"linearizedBaseContracts,omitempty"`
 	Members                []ASTNode         `json:"members,omitempty"`
 	Modifiers              []ModifierInvocation `json:"modifiers,omitempty"`
 	Parameters             *ParameterList    `json:"parameters,omitempty"`
@@ -140,6 +245,18 @@ type ASTNode struct {
 	LeftExpression         *ASTNode          `json:"leftExpression,omitempty"`  // For BinaryOperation
 	RightExpression        *ASTNode          `json:"rightExpression,omitempty"` // For BinaryOperation
 	Indexed 							 *bool 						 `json:"indexed,omitempty"`  				// Indexed parameter for events
+	UnderlyingType         *TypeName         `json:"underlyingType,omitempty"`  // For UserDefinedValueTypeDefinition
+	LibraryName            *TypeName         `json:"libraryName,omitempty"`     // For UsingForDirective
+	FunctionList           []UsingForFunction `json:"functionList,omitempty"`   // For UsingForDirective
+	Global                 bool              `json:"global,omitempty"`          // For UsingForDirective
+	Anonymous              bool              `json:"anonymous,omitempty"`       // For EventDefinition
+}
+
+// UsingForFunction represents an entry of a using-for function list.
+type UsingForFunction struct {
+	Function   						*IdentifierPath 		`json:"function,omitempty"`
+	Definition 						*IdentifierPath 		`json:"definition,omitempty"` // For operator bindings
+	Operator   						string          		`json:"operator,omitempty"`
 }
 
 // BaseContract represents a base contract in inheritance.
@@ -177,6 +294,8 @@ type OverrideSpecifier struct {
 
 // TypeName represents the type of a variable or parameter.
 type TypeName struct {
+		ID               		int               	 `json:"id,omitempty"`
+		Src              		string            	 `json:"src,omitempty"`
 		NodeType         		string            	 `json:"nodeType"`
 		Name             		string            	 `json:"name,omitempty"`
 		Path             		string            	 `json:"path,omitempty"`
@@ -186,6 +305,11 @@ type TypeName struct {
 		ValueType        		*TypeName         	 `json:"valueType,omitempty"`   // For Mapping
 		TypeDescriptions 		*TypeDescriptions 	 `json:"typeDescriptions,omitempty"`
 		PathNode         		*IdentifierPath   	 `json:"pathNode,omitempty"`    // Updated to use IdentifierPath struct
+		ReferencedDeclaration int             	 `json:"referencedDeclaration,omitempty"` // For UserDefinedTypeName
+		StateMutability  		string            	 `json:"stateMutability,omitempty"` // For address payable and FunctionTypeName
+		Visibility       		string            	 `json:"visibility,omitempty"`      // For FunctionTypeName
+		ParameterTypes   		*ParameterList    	 `json:"parameterTypes,omitempty"`  // For FunctionTypeName
+		ReturnParameterTypes *ParameterList    	 `json:"returnParameterTypes,omitempty"` // For FunctionTypeName
 }
 
 type IdentifierPath struct {
@@ -205,76 +329,480 @@ type TypeDescriptions struct {
 
 // ParseAllContracts parses all contracts in the specified data folder.
 func ParseAllContracts(dataFolder string) (map[string]*Contract, error) {
-	contracts := make(map[string]*Contract)
-	err := filepath.Walk(dataFolder, func(path string, info os.FileInfo, err error) error {
+	units, err := ParseAllSourceUnits(dataFolder)
+	if err != nil {
+		return nil, err
+	}
+	return CollectContracts(units), nil
+}
+
+// ParseAllSourceUnits parses every source unit in the specified data folder, or
+// in the single file it points to, keyed by source path. Source units found in
+// several files are only kept once. Files that cannot be parsed are skipped,
+// LoadSourceUnits also returns the diagnostics explaining why.
+func ParseAllSourceUnits(dataFolder string) (map[string]*SourceUnit, error) {
+	units, _, err := LoadSourceUnits(dataFolder)
+	return units, err
+}
+
+// LoadSourceUnits parses the source units like ParseAllSourceUnits, and reports
+// the format of every JSON file. Files without an AST or that fail to parse are
+// skipped instead of aborting the whole walk, and every problem is collected in
+// the diagnostics of the report. Solidity files, and the sources of Sourcify and
+// Etherscan bundles, are parsed from source, and only used when no compiler
+// output covers the same source path.
+func LoadSourceUnits(dataFolder string) (map[string]*SourceUnit, *LoadReport, error) {
+	if _, err := os.Stat(dataFolder); err != nil {
+		return nil, nil, err
+	}
+	units := make(map[string]*SourceUnit)
+	var sources []*SourceUnit
+	var bundles []*MetadataBundle
+	report := &LoadReport{Diagnostics: NewDiagnostics()}
+	filepath.Walk(dataFolder, func(path string, info os.FileInfo, err error) error {
+		diags := report.Diagnostics.ForFile(path)
 		if err != nil {
-			return fmt.Errorf("error accessing file %s: %w", path, err)
+			diags.Errorf(0, "", "cannot access file: %v", err)
+			return nil
 		}
-		if !info.IsDir() && filepath.Ext(path) == ".json" {
-			contract, err := ParseContractFile(path)
+		if info.IsDir() {
+			if isMetadataSources(path) {
+				// Read with the metadata file, under the paths it lists
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".json":
+			data, err := os.ReadFile(path)
 			if err != nil {
-				return fmt.Errorf("error parsing file %s: %w", path, err)
+				diags.Errorf(0, "", "cannot read file: %v", err)
+				return nil
 			}
-			if contract.Name != "" {
-				contracts[contract.Name] = contract
+			file := LoadedFile{Path: path, Format: SniffFormat(data)}
+			if file.Format == FormatSourcify || file.Format == FormatEtherscan {
+				bundle, err := parseMetadataBundle(path, data, file.Format, diags)
+				if err != nil {
+					file.Skipped = err.Error()
+				} else {
+					file.Units = len(bundle.Units)
+					sources = append(sources, bundle.Units...)
+					bundles = append(bundles, bundle)
+				}
+				report.add(file, diags)
+				return nil
+			}
+			fileUnits, err := parseSourceUnits(path, data, file.Format, diags)
+			if err != nil {
+				file.Skipped = err.Error()
+			}
+			file.Units = len(fileUnits)
+			report.add(file, diags)
+			for _, unit := range fileUnits {
+				if unit.Path == "" {
+					unit.Path = path
+				}
+				if existing, ok := units[unit.Path]; ok {
+					mergeArtifacts(existing, unit)
+				} else {
+					units[unit.Path] = unit
+				}
 			}
+		case ".sol":
+			file := LoadedFile{Path: path, Format: FormatSolidity}
+			unit, err := ParseSolidityFile(path, sourcePath(dataFolder, path), diags)
+			if err != nil {
+				file.Skipped = err.Error()
+			} else {
+				file.Units = 1
+				sources = append(sources, unit)
+			}
+			report.add(file, diags)
 		}
 		return nil
 	})
+	for _, unit := range sources {
+		if _, ok := units[unit.Path]; !ok {
+			units[unit.Path] = unit
+		}
+	}
+	// Bundles pair with the compiler output and Solidity files of the project
+	project := make(map[string]*SourceUnit, len(units))
+	for path, unit := range units {
+		project[path] = unit
+	}
+	for _, bundle := range bundles {
+		addBundle(units, project, bundle, report.Diagnostics.ForFile(bundle.Metadata.Path))
+	}
+	resolveUnits(units, report.Diagnostics)
+	return units, report, nil
+}
+
+// resolveUnits runs the passes that need every source unit to be parsed:
+// names of units parsed from source, value types, signatures and getters.
+func resolveUnits(units map[string]*SourceUnit, diags *Diagnostics) {
+	ResolveSourceUnits(units, diags)
+	ResolveValueTypes(units)
+	ResolveSignatures(units)
+	ResolveGetters(units)
+}
+
+// sourcePath returns the path of a Solidity file relative to the data folder,
+// as solc names source units when run from the project root.
+func sourcePath(dataFolder string, path string) string {
+	relative, err := filepath.Rel(dataFolder, path)
+	if err != nil || relative == "." {
+		return filepath.Base(path)
+	}
+	return filepath.ToSlash(relative)
+}
+
+// CollectContracts indexes the contracts of all source units by qualified name
+// and resolves their linearization.
+func CollectContracts(units map[string]*SourceUnit) map[string]*Contract {
+	contracts := make(map[string]*Contract)
+	for _, unit := range units {
+		for _, contract := range unit.Contracts {
+			if contract.Name != "" {
+				contracts[contract.QualifiedName()] = contract
+			}
+		}
+	}
+	ResolveLinearization(contracts)
+	ResolveInterfaceIDs(contracts)
+	return contracts
+}
+
+// ResolveLinearization fills Linearized on every contract by mapping the
+// linearized base contract IDs to the names of the parsed contracts.
+func ResolveLinearization(contracts map[string]*Contract) {
+	byKey := contractsByKey(contracts)
+	for _, contract := range contracts {
+		contract.Linearized = nil
+		for _, id := range contract.LinearizedBaseContracts {
+			name := fmt.Sprintf("#%d", id)
+			if base, ok := byKey[declarationKey{contract.Compilation, id}]; ok {
+				name = base.Name
+			}
+			contract.Linearized = append(contract.Linearized, name)
+		}
+	}
+}
+
+// declarationKey identifies a declaration by the compilation it comes from and
+// its node ID, since node IDs are only unique within a compilation.
+type declarationKey struct {
+	compilation string
+	id          int
+}
+
+// contractsByKey indexes contracts by declaration key, including the keys of
+// their copies merged in from other compilations.
+func contractsByKey(contracts map[string]*Contract) map[declarationKey]*Contract {
+	byKey := make(map[declarationKey]*Contract)
+	for _, contract := range contracts {
+		byKey[declarationKey{contract.Compilation, contract.ID}] = contract
+		for _, alias := range contract.aliases {
+			byKey[alias] = contract
+		}
+	}
+	return byKey
+}
+
+// QualifiedName returns the contract identifier in the form sourcePath:Name.
+func (c *Contract) QualifiedName() string {
+	if c.SourcePath == "" {
+		return c.Name
+	}
+	return c.SourcePath + ":" + c.Name
+}
+
+// LookupContract resolves a contract by qualified name, or by short name when
+// only one parsed contract carries it.
+func LookupContract(contracts map[string]*Contract, name string) (*Contract, error) {
+	if contract, ok := contracts[name]; ok {
+		return contract, nil
+	}
+	var matches []string
+	for key, contract := range contracts {
+		if contract.Name == name {
+			matches = append(matches, key)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("contract %s not found", name)
+	case 1:
+		return contracts[matches[0]], nil
+	default:
+		sort.Strings(matches)
+		return nil, fmt.Errorf("contract name %s is ambiguous: %s", name, strings.Join(matches, ", "))
+	}
+}
+
+// DisplayNames maps each qualified key to the short contract name, falling back
+// to the qualified name when several sources declare the same contract name.
+func DisplayNames(contracts map[string]*Contract) map[string]string {
+	counts := make(map[string]int)
+	for _, contract := range contracts {
+		counts[contract.Name]++
+	}
+	names := make(map[string]string, len(contracts))
+	for key, contract := range contracts {
+		if counts[contract.Name] > 1 {
+			names[key] = key
+		} else {
+			names[key] = contract.Name
+		}
+	}
+	return names
+}
+
+// ParseContractFile parses a single contract file and extracts every contract
+// defined in its source units.
+func ParseContractFile(path string) ([]*Contract, error) {
+	units, err := ParseSourceUnitsFile(path)
 	if err != nil {
 		return nil, err
 	}
+	var contracts []*Contract
+	for _, unit := range units {
+		contracts = append(contracts, unit.Contracts...)
+	}
 	return contracts, nil
 }
 
-// ParseContractFile parses a single contract file and extracts the contract information.
-func ParseContractFile(path string) (*Contract, error) {
+// ParseSourceUnitsFile parses a Foundry artifact, a solc standard-JSON output,
+// a Hardhat or Foundry build-info file, the output of vyper -f ast, or a
+// Sourcify or Etherscan metadata bundle, and extracts every source unit it
+// holds. Types, signatures and getters are resolved within the file.
+func ParseSourceUnitsFile(path string) ([]*SourceUnit, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	units, err := parseSourceUnits(path, data, SniffFormat(data), nil)
+	if err != nil {
+		return nil, err
+	}
+	byPath := make(map[string]*SourceUnit)
+	for _, unit := range units {
+		if unit.Path == "" {
+			unit.Path = path
+		}
+		byPath[unit.Path] = unit
+	}
+	resolveUnits(byPath, nil)
+	return units, nil
+}
 
+// parseSourceUnits extracts the source units of a file of the given format.
+func parseSourceUnits(path string, data []byte, format FileFormat, diags *Diagnostics) ([]*SourceUnit, error) {
+	switch format {
+	case FormatBuildInfo:
+		var buildInfo BuildInfo
+		if err := json.Unmarshal(data, &buildInfo); err != nil {
+			return nil, fmt.Errorf("failed to parse build info %s: %w", path, err)
+		}
+		units, err := ExtractStandardJSONOutput(buildInfo.Output, diags)
+		if err != nil {
+			return nil, fmt.Errorf("build info %s: %w", path, err)
+		}
+		return compiledIn(units, path), nil
+	case FormatStandardJSON:
+		var output StandardJSONOutput
+		if err := json.Unmarshal(data, &output); err != nil {
+			return nil, fmt.Errorf("failed to parse standard JSON output %s: %w", path, err)
+		}
+		units, err := ExtractStandardJSONOutput(output, diags)
+		if err != nil {
+			return nil, fmt.Errorf("standard JSON output %s: %w", path, err)
+		}
+		return compiledIn(units, path), nil
+	case FormatFoundryArtifact, FormatVyperAST:
+		unit, err := parseArtifact(path, data, diags)
+		if err != nil {
+			return nil, err
+		}
+		return []*SourceUnit{unit}, nil
+	case FormatSourcify, FormatEtherscan:
+		bundle, err := parseMetadataBundle(path, data, format, diags)
+		if err != nil {
+			return nil, err
+		}
+		byPath := make(map[string]*SourceUnit)
+		for _, unit := range bundle.Units {
+			byPath[unit.Path] = unit
+		}
+		if err := attachMetadata(byPath, bundle, diags); err != nil {
+			return nil, err
+		}
+		return bundle.Units, nil
+	case FormatABIOnly:
+		return nil, fmt.Errorf("no AST found in file %s, only an ABI", path)
+	}
+	return nil, fmt.Errorf("file %s is not a known compiler output", path)
+}
+
+// compiledIn marks the source units of a build-info, standard-JSON or metadata
+// file as one compilation. Every compilation numbers its AST nodes from
+// scratch, so the node IDs of its units only refer to each other. Artifacts of the same build
+// share the numbering of the compilation they come from, and Vyper and
+// Solidity files get IDs of their own, so they are left unmarked.
+func compiledIn(units []*SourceUnit, compilation string) []*SourceUnit {
+	for _, unit := range units {
+		unit.Compilation = compilation
+		for _, contract := range unit.Contracts {
+			contract.Compilation = compilation
+		}
+	}
+	return units
+}
+
+// parseArtifact extracts the source unit of a Foundry artifact.
+func parseArtifact(path string, data []byte, diags *Diagnostics) (*SourceUnit, error) {
 	var abiFile ABIFile
-	err = json.Unmarshal(data, &abiFile)
+	err := json.Unmarshal(data, &abiFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse contract file %s: %w", path, err)
 	}
 
-	contract := &Contract{
-		Name: abiFile.ContractName,
+	// Process the AST
+	if len(abiFile.AST.Nodes) == 0 {
+		return nil, fmt.Errorf("no AST found in file %s", path)
+	}
+	unit, err := ExtractSourceUnit(abiFile.AST, diags)
+	if err != nil {
+		return nil, err
 	}
 
-	// Process the AST
-	if len(abiFile.AST.Nodes) > 0 {
-		err := ExtractContractInfoFromAST(abiFile.AST, contract)
-		if err != nil {
-			return nil, err
+	// The storage layout and the ABI describe the contract the artifact was
+	// built for, which Foundry only names through the file name
+	name := abiFile.ContractName
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), ".json")
+	}
+	attachCompilerOutput(unit, name, abiFile.ABI, abiFile.StorageLayout, diags)
+	return unit, nil
+}
+
+// attachCompilerOutput sets the ABI and storage layout the compiler produced
+// for the named contract of a source unit. An invalid output is reported and
+// left out, the AST is still usable without it.
+func attachCompilerOutput(unit *SourceUnit, name string, rawABI json.RawMessage, layout *StorageLayout, diags *Diagnostics) {
+	var err error
+	for _, contract := range unit.Contracts {
+		if contract.Name != name {
+			continue
 		}
-	} else {
-		return nil, fmt.Errorf("no AST found in file %s", path)
+		if layout != nil {
+			contract.CompilerStorage, err = layout.Entries()
+			if err != nil {
+				// The computed layout is used instead of a truncated one
+				contract.CompilerStorage = nil
+				diags.Errorf(contract.ID, "", "invalid storage layout of %s: %v", name, err)
+			}
+		}
+		if len(rawABI) > 0 {
+			contract.ABI, err = DecodeABI(rawABI)
+			if err != nil {
+				diags.Errorf(contract.ID, "", "invalid ABI of %s: %v", name, err)
+			}
+		}
+	}
+}
+
+// mergeArtifacts copies the storage layouts and ABIs found in another artifact
+// of the same source unit. Every artifact embeds the whole source unit AST, but
+// only carries the layout and ABI of its own contract. A copy of the unit from
+// another compilation is kept as an alias, so that the node IDs used by that
+// compilation still resolve to the declarations kept; its storage layouts refer
+// to those IDs and are left out.
+func mergeArtifacts(existing *SourceUnit, unit *SourceUnit) {
+	sameCompilation := existing.Compilation == unit.Compilation
+	if !sameCompilation {
+		existing.aliases = append(existing.aliases, unit)
 	}
+	for _, contract := range unit.Contracts {
+		for _, target := range existing.Contracts {
+			if target.Name != contract.Name {
+				continue
+			}
+			if !sameCompilation {
+				target.aliases = append(target.aliases, declarationKey{contract.Compilation, contract.ID})
+			} else if contract.CompilerStorage != nil {
+				target.CompilerStorage = contract.CompilerStorage
+			}
+			if contract.ABI != nil {
+				target.ABI = contract.ABI
+			}
+		}
+	}
+}
 
-	return contract, nil
+// ExtractContractInfoFromAST extracts one Contract per ContractDefinition in the AST.
+// File-level pragma and imports are shared by every contract of the source unit.
+func ExtractContractInfoFromAST(ast AST, diags *Diagnostics) ([]*Contract, error) {
+	unit, err := ExtractSourceUnit(ast, diags)
+	if err != nil {
+		return nil, err
+	}
+	return unit.Contracts, nil
 }
 
-// ExtractContractInfoFromAST extracts information from the AST and populates the contract struct.
-func ExtractContractInfoFromAST(ast AST, contract *Contract) error {
+// ExtractSourceUnit extracts the contracts and file-level declarations of the
+// AST. Nodes that cannot be extracted are reported to diags and left out.
+func ExtractSourceUnit(ast AST, diags *Diagnostics) (*SourceUnit, error) {
+	unit := &SourceUnit{
+		Path:       ast.AbsolutePath,
+		ASTFlavour: ast.Flavour,
+	}
+	diags = diags.ForFile(ast.AbsolutePath)
 	for _, node := range ast.Nodes {
 		switch node.NodeType {
 		case "PragmaDirective":
-			contract.Pragma = ExtractPragmaDirective(node)
+			unit.Pragma = ExtractPragmaDirective(node)
 		case "ImportDirective":
 			imp := ExtractImportDirective(node)
-			contract.Imports = append(contract.Imports, imp)
+			unit.Imports = append(unit.Imports, imp)
 		case "ContractDefinition":
-			// Process the contract definition
-			if contract.Name == "" {
-				contract.Name = node.Name
+			contract := &Contract{
+				ID:                      node.ID,
+				Name:                    node.Name,
+				SourcePath:              ast.AbsolutePath,
+				ASTFlavour:              ast.Flavour,
+				Kind:                    node.ContractKind,
+				Abstract:                node.Abstract,
+				LinearizedBaseContracts: node.LinearizedBaseContracts,
 			}
-			ExtractContractDefinition(node, contract)
+			ExtractContractDefinition(node, contract, diags)
+			unit.Contracts = append(unit.Contracts, contract)
+		case "FunctionDefinition":
+			unit.Functions = append(unit.Functions, ExtractFunction(node, diags))
+		case "VariableDeclaration":
+			unit.Constants = append(unit.Constants, ExtractVariable(node, diags))
+		case "EventDefinition":
+			unit.Events = append(unit.Events, ExtractEvent(node, diags))
+		case "ErrorDefinition":
+			unit.Errors = append(unit.Errors, ExtractError(node, diags))
+		case "UserDefinedValueTypeDefinition":
+			unit.ValueTypes = append(unit.ValueTypes, ExtractUserDefinedValueType(node, diags))
+		case "UsingForDirective":
+			unit.UsingFor = append(unit.UsingFor, ExtractUsingFor(node, diags))
+		case "StructDefinition":
+			unit.Structs = append(unit.Structs, ExtractStruct(node, diags))
+		case "EnumDefinition":
+			unit.Enums = append(unit.Enums, ExtractEnum(node, diags))
+		default:
+			diags.Warnf(node.ID, node.Src, "unsupported top-level node %s", node.NodeType)
 		}
 	}
-	return nil
+	// Directives may appear after a definition, so attach them once the whole unit is read
+	for _, contract := range unit.Contracts {
+		contract.Pragma = unit.Pragma
+		contract.Imports = unit.Imports
+	}
+	return unit, nil
 }
 
 // ExtractPragmaDirective extracts the pragma directive.
@@ -292,7 +820,7 @@ func ExtractImportDirective(node ASTNode) Import {
 }
 
 // ExtractContractDefinition processes the ContractDefinition node.
-func ExtractContractDefinition(node ASTNode, contract *Contract) {
+func ExtractContractDefinition(node ASTNode, contract *Contract, diags *Diagnostics) {
 	// Inheritance
 	for _, baseContract := range node.BaseContracts {
 		contract.Inherits = append(contract.Inherits, baseContract.BaseName.Name)
@@ -301,7 +829,8 @@ func ExtractContractDefinition(node ASTNode, contract *Contract) {
 	for _, member := range node.Nodes {
 		switch member.NodeType {
 		case "VariableDeclaration":
-			variable := ExtractVariable(member)
+			variable := ExtractVariable(member, diags)
+			variable.DeclaredIn = node.Name
 			if member.Constant {
 				contract.Constants = append(contract.Constants, variable)
 			} else if member.TypeName != nil && member.TypeName.NodeType == "Mapping" {
@@ -310,33 +839,57 @@ func ExtractContractDefinition(node ASTNode, contract *Contract) {
 					contract.Variables = append(contract.Variables, variable)
 			}
 		case "FunctionDefinition":
-			function := ExtractFunction(member)
+			function := ExtractFunction(member, diags)
+			function.DeclaredIn = node.Name
 			if function.Kind == "constructor" {
 				contract.Constructor = &function
+			} else if function.Kind == "fallback" {
+				contract.Fallback = &function
+			} else if function.Kind == "receive" {
+				contract.Receive = &function
 			} else {
 				contract.Functions = append(contract.Functions, function)
 			}
 		case "EventDefinition":
-			event := ExtractEvent(member)
+			event := ExtractEvent(member, diags)
+			event.DeclaredIn = node.Name
 			contract.Events = append(contract.Events, event)
+		case "ErrorDefinition":
+			customError := ExtractError(member, diags)
+			customError.DeclaredIn = node.Name
+			contract.Errors = append(contract.Errors, customError)
+		case "UserDefinedValueTypeDefinition":
+			valueType := ExtractUserDefinedValueType(member, diags)
+			valueType.DeclaredIn = node.Name
+			contract.ValueTypes = append(contract.ValueTypes, valueType)
+		case "UsingForDirective":
+			contract.UsingFor = append(contract.UsingFor, ExtractUsingFor(member, diags))
 		case "ModifierDefinition":
-			modifier := ExtractModifier(member)
+			modifier := ExtractModifier(member, diags)
+			modifier.DeclaredIn = node.Name
 			contract.Modifiers = append(contract.Modifiers, modifier)
 		case "StructDefinition":
-			strct := ExtractStruct(member)
+			strct := ExtractStruct(member, diags)
 			contract.Structs = append(contract.Structs, strct)
 		case "EnumDefinition":
-			enum := ExtractEnum(member)
+			enum := ExtractEnum(member, diags)
 			contract.Enums = append(contract.Enums, enum)
+		default:
+			diags.Warnf(member.ID, member.Src, "unsupported member %s of contract %s", member.NodeType, node.Name)
 		}
 	}
 }
 
 // ExtractVariable extracts a variable declaration.
-func ExtractVariable(node ASTNode) Variable {
+func ExtractVariable(node ASTNode, diags *Diagnostics) Variable {
+	if node.TypeName == nil {
+		diags.Warnf(node.ID, node.Src, "variable %s has no type name", node.Name)
+	}
 	variable := Variable{
+		ID:               node.ID,
 		Name:             node.Name,
-		Type:             extractTypeName(node.TypeName),
+		Type:             extractTypeName(node.TypeName, diags),
+		TypeInfo:         ExtractType(node.TypeName, diags),
 		Visibility:       node.Visibility,
 		StateVariable:    node.StateVariable,
 		StorageLocation:  node.StorageLocation,
@@ -346,14 +899,15 @@ func ExtractVariable(node ASTNode) Variable {
 	}
 	// Extract initial value if available
 	if node.Value != nil {
-		variable.Value = extractValue(node.Value)
+		variable.Value = extractValue(node.Value, diags)
 	}
 	return variable
 }
 
 // ExtractFunction extracts a function definition.
-func ExtractFunction(node ASTNode) Function {
+func ExtractFunction(node ASTNode, diags *Diagnostics) Function {
 	function := Function{
+		ID:              node.ID,
 		Name:            node.Name,
 		Kind:            node.Kind,
 		Visibility:      node.Visibility,
@@ -370,21 +924,30 @@ func ExtractFunction(node ASTNode) Function {
 	// Parameters
 	if node.Parameters != nil {
 		for _, paramNode := range node.Parameters.Parameters {
-			param := ExtractParameter(paramNode)
+			param := ExtractParameter(paramNode, diags)
 			function.Parameters = append(function.Parameters, param)
 		}
 	}
 	// Return Parameters
 	if node.ReturnParameters != nil {
 		for _, paramNode := range node.ReturnParameters.Parameters {
-			param := ExtractParameter(paramNode)
+			param := ExtractParameter(paramNode, diags)
 			function.ReturnParameters = append(function.ReturnParameters, param)
 		}
 	}
+	function.Signature = CanonicalSignature(function.Name, function.Parameters)
+	// Prefer the selector emitted by the compiler
+	function.Selector = node.FunctionSelector
+	function.selectorFromAST = node.FunctionSelector != ""
+	if !function.selectorFromAST && isExternallyCallable(function) {
+		function.Selector = Selector(function.Signature)
+	}
 	return function
 }
 
-func extractValueFromNode(node *ASTNode) string {
+// extractValueFromNode renders an expression used as an initial value or an
+// array length.
+func extractValueFromNode(node *ASTNode, diags *Diagnostics) string {
 	if node == nil {
 		return ""
 	}
@@ -397,18 +960,18 @@ func extractValueFromNode(node *ASTNode) string {
 			return node.HexValue
 		}
 	case "FunctionCall":
-		return extractFunctionCall(node)
+		return extractFunctionCall(node, diags)
 	case "Identifier":
 		return node.Name
 	case "UnaryOperation":
-		operand := extractValue(node.SubExpression)
+		operand := extractValue(node.SubExpression, diags)
 		return fmt.Sprintf("%s%s", node.Operator, operand)
 	case "BinaryOperation":
-		left := extractValue(node.LeftExpression)
-		right := extractValue(node.RightExpression)
+		left := extractValue(node.LeftExpression, diags)
+		right := extractValue(node.RightExpression, diags)
 		return fmt.Sprintf("(%s %s %s)", left, node.Operator, right)
 	default:
-		fmt.Printf("Unhandled node type in value extraction: %s\n", node.NodeType)
+		diags.Warnf(node.ID, node.Src, "unhandled expression %s in value", node.NodeType)
 		return ""
 	}
 	return ""
@@ -417,29 +980,117 @@ func extractValueFromNode(node *ASTNode) string {
 
 
 // ExtractEvent extracts an event definition.
-func ExtractEvent(node ASTNode) Event {
+func ExtractEvent(node ASTNode, diags *Diagnostics) Event {
 	event := Event{
-		Name: node.Name,
+		ID:        node.ID,
+		Name:      node.Name,
+		Anonymous: node.Anonymous,
 	}
 	// Parameters
 	if node.Parameters != nil {
 		for _, paramNode := range node.Parameters.Parameters {
-			param := ExtractParameter(paramNode)
+			param := ExtractParameter(paramNode, diags)
 			event.Parameters = append(event.Parameters, param)
 		}
 	}
+	event.Signature = CanonicalSignature(event.Name, event.Parameters)
+	setEventTopic(&event)
+	event.Warning = validateEvent(event)
 	return event
 }
 
+// setEventTopic computes topic0 from the event signature. Anonymous events do
+// not emit it.
+func setEventTopic(event *Event) {
+	event.Topic0 = ""
+	if !event.Anonymous {
+		event.Topic0 = Keccak256Hex(event.Signature)
+	}
+}
+
+// validateEvent checks the number of indexed parameters: topic0 takes one of
+// the four log topics unless the event is anonymous.
+func validateEvent(event Event) string {
+	indexed := 0
+	for _, param := range event.Parameters {
+		if param.Indexed {
+			indexed++
+		}
+	}
+	limit := 3
+	if event.Anonymous {
+		limit = 4
+	}
+	if indexed > limit {
+		return fmt.Sprintf("%d indexed parameters, at most %d are allowed", indexed, limit)
+	}
+	return ""
+}
+
+// ExtractError extracts a custom error definition and computes its selector.
+func ExtractError(node ASTNode, diags *Diagnostics) Error {
+	customError := Error{
+		ID:   node.ID,
+		Name: node.Name,
+	}
+	// Parameters
+	if node.Parameters != nil {
+		for _, paramNode := range node.Parameters.Parameters {
+			param := ExtractParameter(paramNode, diags)
+			customError.Parameters = append(customError.Parameters, param)
+		}
+	}
+	customError.Signature = CanonicalSignature(customError.Name, customError.Parameters)
+	customError.Selector = Selector(customError.Signature)
+	return customError
+}
+
+// ExtractUserDefinedValueType extracts a user-defined value type definition.
+func ExtractUserDefinedValueType(node ASTNode, diags *Diagnostics) UserDefinedValueType {
+	return UserDefinedValueType{
+		ID:                 node.ID,
+		Name:               node.Name,
+		UnderlyingType:     extractTypeName(node.UnderlyingType, diags),
+		UnderlyingTypeInfo: ExtractType(node.UnderlyingType, diags),
+	}
+}
+
+// ExtractUsingFor extracts a using-for directive.
+func ExtractUsingFor(node ASTNode, diags *Diagnostics) UsingFor {
+	usingFor := UsingFor{
+		ID:       node.ID,
+		TypeName: "*",
+		Global:   node.Global,
+	}
+	if node.LibraryName != nil {
+		usingFor.Library = node.LibraryName.Name
+		if usingFor.Library == "" && node.LibraryName.PathNode != nil {
+			usingFor.Library = node.LibraryName.PathNode.Name
+		}
+	}
+	for _, entry := range node.FunctionList {
+		if entry.Function != nil {
+			usingFor.Functions = append(usingFor.Functions, entry.Function.Name)
+		} else if entry.Definition != nil {
+			usingFor.Functions = append(usingFor.Functions, fmt.Sprintf("%s as %s", entry.Definition.Name, entry.Operator))
+		}
+	}
+	if node.TypeName != nil {
+		usingFor.TypeName = extractTypeName(node.TypeName, diags)
+	}
+	return usingFor
+}
+
 // ExtractModifier extracts a function modifier.
-func ExtractModifier(node ASTNode) Modifier {
+func ExtractModifier(node ASTNode, diags *Diagnostics) Modifier {
 	modifier := Modifier{
+		ID:   node.ID,
 		Name: node.Name,
 	}
 	// Parameters
 	if node.Parameters != nil {
 		for _, paramNode := range node.Parameters.Parameters {
-			param := ExtractParameter(paramNode)
+			param := ExtractParameter(paramNode, diags)
 			modifier.Parameters = append(modifier.Parameters, param)
 		}
 	}
@@ -447,20 +1098,22 @@ func ExtractModifier(node ASTNode) Modifier {
 }
 
 // ExtractStruct extracts a struct definition.
-func ExtractStruct(node ASTNode) Struct {
+func ExtractStruct(node ASTNode, diags *Diagnostics) Struct {
 	s := Struct{
+		ID:   node.ID,
 		Name: node.Name,
 	}
 	for _, member := range node.Members {
-		variable := ExtractVariable(member)
+		variable := ExtractVariable(member, diags)
 		s.Members = append(s.Members, variable)
 	}
 	return s
 }
 
 // ExtractEnum extracts an enum definition.
-func ExtractEnum(node ASTNode) Enum {
+func ExtractEnum(node ASTNode, diags *Diagnostics) Enum {
 	enum := Enum{
+		ID:   node.ID,
 		Name: node.Name,
 	}
 	for _, member := range node.Members {
@@ -472,10 +1125,11 @@ func ExtractEnum(node ASTNode) Enum {
 }
 
 // ExtractParameter extracts a parameter from a VariableDeclaration node.
-func ExtractParameter(node ASTNode) Parameter {
+func ExtractParameter(node ASTNode, diags *Diagnostics) Parameter {
 	param := Parameter{
-		Name: node.Name,
-		Type: extractTypeName(node.TypeName),
+		Name:        node.Name,
+		Type:        extractTypeName(node.TypeName, diags),
+		TypeInfo:    ExtractType(node.TypeName, diags),
 	}
 
 	// Check if 'Indexed' is set (only relevant for event parameters)
@@ -499,7 +1153,7 @@ func ExtractModifiers(node ASTNode) []string {
 }
 
 // extractTypeName extracts the type name from a TypeName node.
-func extractTypeName(typeName *TypeName) string {
+func extractTypeName(typeName *TypeName, diags *Diagnostics) string {
 	if typeName == nil {
 		return ""
 	}
@@ -516,22 +1170,27 @@ func extractTypeName(typeName *TypeName) string {
 		}
 		return ""
 	case "Mapping":
-		keyType := extractTypeName(typeName.KeyType)
-		valueType := extractTypeName(typeName.ValueType)
+		keyType := extractTypeName(typeName.KeyType, diags)
+		valueType := extractTypeName(typeName.ValueType, diags)
 		return fmt.Sprintf("mapping(%s => %s)", keyType, valueType)
 	case "ArrayTypeName":
-		baseType := extractTypeName(typeName.BaseType)
+		baseType := extractTypeName(typeName.BaseType, diags)
 		if typeName.Length != nil {
-			return fmt.Sprintf("%s[%v]", baseType, typeName.Length)
+			return fmt.Sprintf("%s[%s]", baseType, extractValue(typeName.Length, diags))
 		}
 		return fmt.Sprintf("%s[]", baseType)
+	case "FunctionTypeName":
+		if typeName.TypeDescriptions != nil && typeName.TypeDescriptions.TypeString != "" {
+			return typeName.TypeDescriptions.TypeString
+		}
+		return "function"
 	default:
 		return ""
 	}
 }
 
 // extractValue extracts the value from an ASTNode representing a value.
-func extractValue(value interface{}) string {
+func extractValue(value interface{}, diags *Diagnostics) string {
 	if value == nil {
 		return ""
 	}
@@ -541,29 +1200,31 @@ func extractValue(value interface{}) string {
 		return v
 	case float64, int, bool:
 		return fmt.Sprintf("%v", v)
+	case *ASTNode:
+		return extractValueFromNode(v, diags)
 	case map[string]interface{}:
 		// This is likely an ASTNode represented as a map
 		nodeData, err := json.Marshal(v)
 		if err != nil {
-			fmt.Printf("Error marshaling node: %v\n", err)
+			diags.Errorf(0, "", "invalid value node: %v", err)
 			return ""
 		}
 		node := &ASTNode{}
 		err = json.Unmarshal(nodeData, node)
 		if err != nil {
-			fmt.Printf("Error unmarshaling node: %v\n", err)
+			diags.Errorf(0, "", "invalid value node: %v", err)
 			return ""
 		}
-		return extractValueFromNode(node)
+		return extractValueFromNode(node, diags)
 	default:
-		fmt.Printf("Unhandled value type: %T\n", v)
+		diags.Warnf(0, "", "unhandled value of type %T", v)
 		return ""
 	}
 }
 
 
 // extractFunctionCall extracts information from a FunctionCall node used as a value.
-func extractFunctionCall(node *ASTNode) string {
+func extractFunctionCall(node *ASTNode, diags *Diagnostics) string {
 	if node == nil || node.Expression == nil {
 		return ""
 	}
@@ -571,11 +1232,11 @@ func extractFunctionCall(node *ASTNode) string {
 	if node.Expression.NodeType == "Identifier" {
 		functionName = node.Expression.Name
 	} else {
-		functionName = extractValue(node.Expression)
+		functionName = extractValue(node.Expression, diags)
 	}
 	args := []string{}
 	for _, arg := range node.Arguments {
-		argValue := extractValue(arg)
+		argValue := extractValue(arg, diags)
 		args = append(args, argValue)
 	}
 	return fmt.Sprintf("%s(%s)", functionName, strings.Join(args, ", "))
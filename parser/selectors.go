@@ -0,0 +1,199 @@
+// selectors.go
+package parser
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// typeRegistry indexes the declarations needed to turn user-defined types into
+// their canonical ABI form.
+type typeRegistry struct {
+	structs    map[declarationKey]Struct
+	enums      map[declarationKey]Enum
+	valueTypes map[declarationKey]UserDefinedValueType
+}
+
+// newTypeRegistry indexes the structs and value types of all source units,
+// and of their copies from other compilations.
+func newTypeRegistry(units map[string]*SourceUnit) *typeRegistry {
+	registry := &typeRegistry{
+		structs:    make(map[declarationKey]Struct),
+		enums:      make(map[declarationKey]Enum),
+		valueTypes: make(map[declarationKey]UserDefinedValueType),
+	}
+	for _, unit := range units {
+		for _, source := range append([]*SourceUnit{unit}, unit.aliases...) {
+			registry.add(source.Compilation, source.Structs, source.Enums, source.ValueTypes)
+			for _, contract := range source.Contracts {
+				registry.add(source.Compilation, contract.Structs, contract.Enums, contract.ValueTypes)
+			}
+		}
+	}
+	return registry
+}
+
+func (r *typeRegistry) add(compilation string, structs []Struct, enums []Enum, valueTypes []UserDefinedValueType) {
+	for _, strct := range structs {
+		r.structs[declarationKey{compilation, strct.ID}] = strct
+	}
+	for _, enum := range enums {
+		r.enums[declarationKey{compilation, enum.ID}] = enum
+	}
+	for _, valueType := range valueTypes {
+		r.valueTypes[declarationKey{compilation, valueType.ID}] = valueType
+	}
+}
+
+// ResolveSignatures links struct, enum and value type references to their
+// declarations, then recomputes the canonical signatures, selectors and event
+// topics once every struct and value type is known.
+func ResolveSignatures(units map[string]*SourceUnit) {
+	registry := newTypeRegistry(units)
+	forEachParameter(units, func(unit *SourceUnit, param *Parameter) {
+		registry.resolveType(unit.Compilation, param.TypeInfo, make(map[int]bool))
+	})
+	forEachVariable(units, func(unit *SourceUnit, variable *Variable) {
+		registry.resolveType(unit.Compilation, variable.TypeInfo, make(map[int]bool))
+	})
+
+	for _, unit := range units {
+		resolveFunctionSignatures(unit.Functions)
+		resolveEventSignatures(unit.Events)
+		resolveErrorSignatures(unit.Errors)
+		for _, contract := range unit.Contracts {
+			resolveFunctionSignatures(contract.Functions)
+			resolveEventSignatures(contract.Events)
+			resolveErrorSignatures(contract.Errors)
+		}
+	}
+}
+
+// resolveType fills the struct components and value type underlying types
+// referenced by t, a type of the given compilation. visiting guards against
+// recursive structs.
+func (r *typeRegistry) resolveType(compilation string, t *Type, visiting map[int]bool) {
+	if t == nil {
+		return
+	}
+	switch t.Kind {
+	case TypeArray:
+		r.resolveType(compilation, t.BaseType, visiting)
+	case TypeMapping:
+		r.resolveType(compilation, t.KeyType, visiting)
+		r.resolveType(compilation, t.ValueType, visiting)
+	case TypeFunction:
+		for _, param := range t.Parameters {
+			r.resolveType(compilation, param, visiting)
+		}
+		for _, param := range t.ReturnParameters {
+			r.resolveType(compilation, param, visiting)
+		}
+	case TypeUserDefined:
+		switch t.DeclarationKind {
+		case "userDefinedValueType":
+			if valueType, ok := r.valueTypes[declarationKey{compilation, t.ReferencedDeclaration}]; ok {
+				t.Underlying = valueType.UnderlyingTypeInfo
+			}
+		case "enum":
+			if enum, ok := r.enums[declarationKey{compilation, t.ReferencedDeclaration}]; ok {
+				t.EnumValues = len(enum.Values)
+			}
+		case "struct":
+			strct, ok := r.structs[declarationKey{compilation, t.ReferencedDeclaration}]
+			if !ok || visiting[strct.ID] {
+				return
+			}
+			visiting[strct.ID] = true
+			t.Components = nil
+			for _, member := range strct.Members {
+				r.resolveType(compilation, member.TypeInfo, visiting)
+				t.Components = append(t.Components, member.TypeInfo)
+			}
+			delete(visiting, strct.ID)
+		}
+	}
+}
+
+func resolveFunctionSignatures(functions []Function) {
+	for i := range functions {
+		functions[i].Signature = CanonicalSignature(functions[i].Name, functions[i].Parameters)
+		// Compiler-provided selectors are authoritative, library selectors in particular
+		if isExternallyCallable(functions[i]) && !functions[i].selectorFromAST {
+			functions[i].Selector = Selector(functions[i].Signature)
+		}
+	}
+}
+
+func resolveEventSignatures(events []Event) {
+	for i := range events {
+		events[i].Signature = CanonicalSignature(events[i].Name, events[i].Parameters)
+		setEventTopic(&events[i])
+	}
+}
+
+func resolveErrorSignatures(errors []Error) {
+	for i := range errors {
+		errors[i].Signature = CanonicalSignature(errors[i].Name, errors[i].Parameters)
+		errors[i].Selector = Selector(errors[i].Signature)
+	}
+}
+
+// CanonicalSignature builds the canonical ABI signature name(type1,type2,...).
+// Structs are only flattened to tuples once ResolveSignatures has linked them
+// to their declarations.
+func CanonicalSignature(name string, params []Parameter) string {
+	var types []string
+	for _, param := range params {
+		switch {
+		case param.TypeInfo != nil:
+			types = append(types, param.TypeInfo.Canonical())
+		case param.UnderlyingType != "":
+			types = append(types, canonicalType(param.UnderlyingType))
+		default:
+			types = append(types, canonicalType(param.Type))
+		}
+	}
+	return name + "(" + strings.Join(types, ",") + ")"
+}
+
+// Selector returns the first 4 bytes of the keccak256 hash of a signature, hex encoded.
+func Selector(signature string) string {
+	return hex.EncodeToString(crypto.Keccak256([]byte(signature))[:4])
+}
+
+// Keccak256Hex returns the keccak256 hash of a signature, hex encoded.
+func Keccak256Hex(signature string) string {
+	return hex.EncodeToString(crypto.Keccak256([]byte(signature)))
+}
+
+// isExternallyCallable reports whether a function is part of the contract ABI.
+func isExternallyCallable(function Function) bool {
+	return function.Kind == "function" && (function.Visibility == "public" || function.Visibility == "external")
+}
+
+// canonicalType converts a display type to the type used in ABI signatures.
+func canonicalType(typ string) string {
+	// Array suffixes are kept as is around the converted base type
+	if i := strings.Index(typ, "["); i > 0 {
+		return canonicalType(typ[:i]) + typ[i:]
+	}
+	switch {
+	case typ == "uint":
+		return "uint256"
+	case typ == "int":
+		return "int256"
+	case typ == "byte":
+		// Alias of bytes1 until 0.8
+		return "bytes1"
+	case typ == "address payable":
+		return "address"
+	case strings.HasPrefix(typ, "contract "), strings.HasPrefix(typ, "interface "):
+		return "address"
+	case strings.HasPrefix(typ, "enum "):
+		return "uint8"
+	}
+	return typ
+}
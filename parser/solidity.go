@@ -0,0 +1,1247 @@
+// solidity.go
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ASTSource is the flavour of ASTs parsed from Solidity source code, without
+// the compiler.
+const ASTSource = "source"
+
+// ParseSolidityFile parses a Solidity source file and extracts its source unit,
+// with sourcePath as the path of the unit.
+func ParseSolidityFile(filePath string, sourcePath string, diags *Diagnostics) (*SourceUnit, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	ast, err := ParseSolidity(sourcePath, string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s:%w", filePath, err)
+	}
+	return ExtractSourceUnit(ast, diags)
+}
+
+// ParseSolidity parses the declarations of Solidity source code into an AST
+// shaped like the one solc outputs. Function bodies and expressions are
+// skipped, and user-defined type names are left unresolved until
+// ResolveSourceUnits runs over every parsed file.
+func ParseSolidity(sourcePath string, src string) (AST, error) {
+	tokens, err := Tokenize(src)
+	if err != nil {
+		return AST{}, err
+	}
+	p := &solidityParser{
+		src:    src,
+		path:   sourcePath,
+		tokens: tokens,
+		idBase: syntheticIDBase(sourcePath),
+	}
+	ast := AST{AbsolutePath: sourcePath, Flavour: ASTSource}
+	for !p.at(TokenEOF) {
+		node, err := p.sourceUnitMember()
+		if err != nil {
+			return AST{}, err
+		}
+		if node != nil {
+			ast.Nodes = append(ast.Nodes, *node)
+		}
+	}
+	return ast, nil
+}
+
+// solidityParser is a recursive descent parser over the declaration layer.
+type solidityParser struct {
+	src    string
+	path   string
+	tokens []Token
+	pos    int
+	idBase int
+	nodes  int
+}
+
+// parseError describes an unexpected token.
+type parseError struct {
+	token    Token
+	expected string
+}
+
+func (e *parseError) Error() string {
+	found := e.token.Text
+	if e.token.Kind == TokenEOF {
+		found = TokenEOF
+	}
+	return fmt.Sprintf("%d:%d: expected %s, found %q", e.token.Line, e.token.Col, e.expected, found)
+}
+
+// newID returns the ID of a new node.
+func (p *solidityParser) newID() int {
+	p.nodes++
+	return syntheticID(p.idBase, p.nodes)
+}
+
+func (p *solidityParser) peek() Token {
+	return p.tokens[p.pos]
+}
+
+// peekAt returns the token offset tokens ahead.
+func (p *solidityParser) peekAt(offset int) Token {
+	if p.pos+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *solidityParser) next() Token {
+	token := p.tokens[p.pos]
+	if token.Kind != TokenEOF {
+		p.pos++
+	}
+	return token
+}
+
+// at reports whether the current token has the given kind.
+func (p *solidityParser) at(kind string) bool {
+	return p.peek().Kind == kind
+}
+
+// is reports whether the current token is the given keyword or punctuation.
+func (p *solidityParser) is(text string) bool {
+	token := p.peek()
+	return token.Text == text && token.Kind != TokenString
+}
+
+// accept consumes the current token if it is text.
+func (p *solidityParser) accept(text string) bool {
+	if p.is(text) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// expect consumes the current token, which must be text.
+func (p *solidityParser) expect(text string) error {
+	if !p.accept(text) {
+		return &parseError{token: p.peek(), expected: fmt.Sprintf("%q", text)}
+	}
+	return nil
+}
+
+// identifier consumes an identifier.
+func (p *solidityParser) identifier() (string, error) {
+	if !p.at(TokenIdentifier) {
+		return "", &parseError{token: p.peek(), expected: "identifier"}
+	}
+	return p.next().Text, nil
+}
+
+// skipBalanced skips a bracketed group starting at the current token, which
+// must be open, up to and including its matching close.
+func (p *solidityParser) skipBalanced(open string, close string) error {
+	start := p.peek()
+	if err := p.expect(open); err != nil {
+		return err
+	}
+	depth := 1
+	for depth > 0 {
+		token := p.next()
+		switch {
+		case token.Kind == TokenEOF:
+			return &parseError{token: start, expected: fmt.Sprintf("matching %q", close)}
+		case token.Kind == TokenString:
+		case token.Text == open:
+			depth++
+		case token.Text == close:
+			depth--
+		}
+	}
+	return nil
+}
+
+// skipUntil skips tokens up to one of the given texts at bracket depth 0,
+// leaving it as the current token, and returns the skipped source text.
+func (p *solidityParser) skipUntil(texts ...string) (string, error) {
+	start := p.peek()
+	depth := 0
+	for {
+		token := p.peek()
+		if token.Kind == TokenEOF {
+			return "", &parseError{token: token, expected: strings.Join(quoteAll(texts), " or ")}
+		}
+		if token.Kind != TokenString {
+			if depth == 0 {
+				for _, text := range texts {
+					if token.Text == text {
+						return strings.Join(strings.Fields(p.src[start.Start:token.Start]), " "), nil
+					}
+				}
+			}
+			switch token.Text {
+			case "(", "[", "{":
+				depth++
+			case ")", "]", "}":
+				depth--
+			}
+		}
+		p.next()
+	}
+}
+
+// srcFrom returns the src range from the byte offset start to the end of the
+// last consumed token. The source index is -1, as solc gives for sources it has
+// no index for.
+func (p *solidityParser) srcFrom(start int) string {
+	end := p.tokens[max(p.pos-1, 0)].End
+	return fmt.Sprintf("%d:%d:-1", start, end-start)
+}
+
+// quoteAll quotes every text.
+func quoteAll(texts []string) []string {
+	quoted := make([]string, len(texts))
+	for i, text := range texts {
+		quoted[i] = fmt.Sprintf("%q", text)
+	}
+	return quoted
+}
+
+// sourceUnitMember parses a top-level declaration. It returns nil for
+// declarations the model has no place for.
+func (p *solidityParser) sourceUnitMember() (*ASTNode, error) {
+	switch {
+	case p.is("pragma"):
+		p.next()
+		text, err := p.skipUntil(";")
+		if err != nil {
+			return nil, err
+		}
+		p.next()
+		return &ASTNode{ID: p.newID(), NodeType: "PragmaDirective", Literals: strings.Fields(text)}, nil
+	case p.is("import"):
+		return p.importDirective()
+	case p.is("abstract"), p.is("contract"), p.is("interface"), p.is("library"):
+		return p.contractDefinition()
+	case p.accept(";"):
+		return nil, nil
+	}
+	return p.declaration("")
+}
+
+// importDirective parses the forms import "a.sol" [as A];, import * as A
+// from "a.sol"; and import {B, C as D} from "a.sol";.
+func (p *solidityParser) importDirective() (*ASTNode, error) {
+	p.next()
+	node := &ASTNode{ID: p.newID(), NodeType: "ImportDirective"}
+	if p.at(TokenString) {
+		node.File = unquote(p.next().Text)
+		if p.accept("as") {
+			alias, err := p.identifier()
+			if err != nil {
+				return nil, err
+			}
+			node.Name = alias
+		}
+	} else {
+		if p.accept("*") {
+			if err := p.expect("as"); err != nil {
+				return nil, err
+			}
+			alias, err := p.identifier()
+			if err != nil {
+				return nil, err
+			}
+			node.Name = alias
+		} else if p.is("{") {
+			if err := p.skipBalanced("{", "}"); err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, &parseError{token: p.peek(), expected: "import path"}
+		}
+		if err := p.expect("from"); err != nil {
+			return nil, err
+		}
+		if !p.at(TokenString) {
+			return nil, &parseError{token: p.peek(), expected: "import path"}
+		}
+		node.File = unquote(p.next().Text)
+	}
+	node.AbsolutePath = node.File
+	if strings.HasPrefix(node.File, ".") {
+		node.AbsolutePath = path.Join(path.Dir(p.path), node.File)
+	}
+	return node, p.expect(";")
+}
+
+// contractDefinition parses a contract, interface or library.
+func (p *solidityParser) contractDefinition() (*ASTNode, error) {
+	node := &ASTNode{ID: p.newID(), NodeType: "ContractDefinition"}
+	node.Abstract = p.accept("abstract")
+	node.ContractKind = p.next().Text
+	name, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	node.Name = name
+
+	if p.accept("is") {
+		for {
+			base, err := p.identifierPath()
+			if err != nil {
+				return nil, err
+			}
+			node.BaseContracts = append(node.BaseContracts, BaseContract{BaseName: BaseName{Name: base}})
+			// Base constructor arguments
+			if p.is("(") {
+				if err := p.skipBalanced("(", ")"); err != nil {
+					return nil, err
+				}
+			}
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	// Storage layout specifier
+	if p.is("layout") {
+		if _, err := p.skipUntil("{"); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for !p.accept("}") {
+		if p.at(TokenEOF) {
+			return nil, &parseError{token: p.peek(), expected: `"}"`}
+		}
+		if p.accept(";") {
+			continue
+		}
+		member, err := p.declaration(node.Name)
+		if err != nil {
+			return nil, err
+		}
+		if member != nil {
+			node.Nodes = append(node.Nodes, *member)
+		}
+	}
+	return node, nil
+}
+
+// declaration parses a declaration allowed in a contract, or at file level
+// when contractName is empty.
+func (p *solidityParser) declaration(contractName string) (*ASTNode, error) {
+	switch {
+	case p.is("function"), p.is("constructor"), p.is("fallback"), p.is("receive"):
+		// fallback and receive are also valid names for state variables of
+		// older versions, only a parameter list makes them functions
+		if p.is("function") && p.functionTypeVariable() {
+			break
+		}
+		if p.is("function") || p.peekAt(1).Text == "(" {
+			return p.functionDefinition(contractName)
+		}
+	case p.is("modifier"):
+		return p.modifierDefinition()
+	case p.is("event"):
+		return p.eventDefinition()
+	case p.is("error") && p.peekAt(1).Kind == TokenIdentifier && p.peekAt(2).Text == "(":
+		return p.errorDefinition()
+	case p.is("struct"):
+		return p.structDefinition()
+	case p.is("enum"):
+		return p.enumDefinition()
+	case p.is("type") && p.peekAt(1).Kind == TokenIdentifier && p.peekAt(2).Text == "is":
+		return p.valueTypeDefinition()
+	case p.is("using"):
+		return p.usingForDirective()
+	}
+	return p.stateVariable()
+}
+
+// functionTypeVariable reports whether the declaration starting with the
+// function keyword is a state variable of function type, which ends with its
+// name or an initial value instead of a body.
+func (p *solidityParser) functionTypeVariable() bool {
+	if p.peekAt(1).Text != "(" {
+		return false
+	}
+	depth := 0
+	for i := p.pos; i < len(p.tokens); i++ {
+		token := p.tokens[i]
+		if token.Kind == TokenString {
+			continue
+		}
+		switch token.Text {
+		case "(", "[":
+			depth++
+		case ")", "]":
+			depth--
+		}
+		if depth > 0 {
+			continue
+		}
+		switch {
+		case token.Kind == TokenEOF, token.Text == "{":
+			return false
+		case token.Text == "=":
+			return true
+		case token.Text == ";":
+			last := p.tokens[i-1]
+			if last.Kind != TokenIdentifier {
+				return false
+			}
+			switch last.Text {
+			case "external", "public", "internal", "private", "payable", "view", "pure", "constant", "virtual", "override":
+				return false
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// functionDefinition parses a function, constructor, fallback or receive
+// function, skipping its body.
+func (p *solidityParser) functionDefinition(contractName string) (*ASTNode, error) {
+	node := &ASTNode{ID: p.newID(), NodeType: "FunctionDefinition", StateMutability: "nonpayable"}
+	keyword := p.next().Text
+	switch keyword {
+	case "function":
+		node.Kind = "function"
+		if p.at(TokenIdentifier) {
+			node.Name = p.next().Text
+		}
+		switch {
+		case node.Name == "":
+			// Unnamed fallback function before 0.6
+			node.Kind = "fallback"
+		case contractName != "" && node.Name == contractName:
+			// Constructor named after the contract before 0.4.22
+			node.Kind = "constructor"
+			node.Name = ""
+		}
+	default:
+		node.Kind = keyword
+	}
+
+	var err error
+	if node.Parameters, err = p.parameterList(); err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.is("public"), p.is("external"), p.is("internal"), p.is("private"):
+			node.Visibility = p.next().Text
+		case p.is("pure"), p.is("view"), p.is("payable"):
+			node.StateMutability = p.next().Text
+		case p.is("constant"):
+			p.next()
+			node.StateMutability = "view"
+		case p.is("virtual"):
+			p.next()
+		case p.is("override"):
+			p.next()
+			overrides, err := p.overrideSpecifier()
+			if err != nil {
+				return nil, err
+			}
+			node.Overrides = overrides
+		case p.is("returns"):
+			p.next()
+			if node.ReturnParameters, err = p.parameterList(); err != nil {
+				return nil, err
+			}
+		case p.at(TokenIdentifier):
+			// Modifier invocation, or base constructor call
+			name, err := p.identifierPath()
+			if err != nil {
+				return nil, err
+			}
+			if p.is("(") {
+				if err := p.skipBalanced("(", ")"); err != nil {
+					return nil, err
+				}
+			}
+			node.Modifiers = append(node.Modifiers, ModifierInvocation{
+				ID:           p.newID(),
+				NodeType:     "ModifierInvocation",
+				ModifierName: ASTNode{Name: name},
+			})
+		case p.accept(";"):
+			return p.finishFunction(node, contractName), nil
+		case p.is("{"):
+			if err := p.skipBalanced("{", "}"); err != nil {
+				return nil, err
+			}
+			return p.finishFunction(node, contractName), nil
+		default:
+			return nil, &parseError{token: p.peek(), expected: "function attribute or body"}
+		}
+	}
+}
+
+// finishFunction applies the default visibility of functions declared
+// without one, which was public before 0.5. Free functions, declared outside
+// of any contract, are always internal.
+func (p *solidityParser) finishFunction(node *ASTNode, contractName string) *ASTNode {
+	if node.Visibility == "" {
+		switch {
+		case contractName == "":
+			node.Visibility = "internal"
+		case node.Kind == "fallback" || node.Kind == "receive":
+			node.Visibility = "external"
+		default:
+			node.Visibility = "public"
+		}
+	}
+	return node
+}
+
+// overrideSpecifier parses the optional base list following override.
+func (p *solidityParser) overrideSpecifier() (*OverrideSpecifier, error) {
+	overrides := &OverrideSpecifier{ID: p.newID(), NodeType: "OverrideSpecifier"}
+	if !p.accept("(") {
+		return overrides, nil
+	}
+	for !p.accept(")") {
+		name, err := p.identifierPath()
+		if err != nil {
+			return nil, err
+		}
+		overrides.Overrides = append(overrides.Overrides, ASTNode{Name: name})
+		if !p.accept(",") && !p.is(")") {
+			return nil, &parseError{token: p.peek(), expected: `"," or ")"`}
+		}
+	}
+	return overrides, nil
+}
+
+// modifierDefinition parses a modifier, skipping its body.
+func (p *solidityParser) modifierDefinition() (*ASTNode, error) {
+	p.next()
+	node := &ASTNode{ID: p.newID(), NodeType: "ModifierDefinition", Visibility: "internal"}
+	name, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	node.Name = name
+	if p.is("(") {
+		if node.Parameters, err = p.parameterList(); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		switch {
+		case p.accept("virtual"):
+		case p.accept("override"):
+			if _, err := p.overrideSpecifier(); err != nil {
+				return nil, err
+			}
+		case p.accept(";"):
+			return node, nil
+		case p.is("{"):
+			return node, p.skipBalanced("{", "}")
+		default:
+			return nil, &parseError{token: p.peek(), expected: "modifier body"}
+		}
+	}
+}
+
+// eventDefinition parses an event.
+func (p *solidityParser) eventDefinition() (*ASTNode, error) {
+	p.next()
+	node := &ASTNode{ID: p.newID(), NodeType: "EventDefinition"}
+	name, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	node.Name = name
+	if node.Parameters, err = p.parameterList(); err != nil {
+		return nil, err
+	}
+	node.Anonymous = p.accept("anonymous")
+	return node, p.expect(";")
+}
+
+// errorDefinition parses a custom error.
+func (p *solidityParser) errorDefinition() (*ASTNode, error) {
+	p.next()
+	node := &ASTNode{ID: p.newID(), NodeType: "ErrorDefinition"}
+	node.Name = p.next().Text
+	var err error
+	if node.Parameters, err = p.parameterList(); err != nil {
+		return nil, err
+	}
+	return node, p.expect(";")
+}
+
+// structDefinition parses a struct and its members.
+func (p *solidityParser) structDefinition() (*ASTNode, error) {
+	p.next()
+	node := &ASTNode{ID: p.newID(), NodeType: "StructDefinition", Visibility: "public"}
+	name, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	node.Name = name
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for !p.accept("}") {
+		member := ASTNode{ID: p.newID(), NodeType: "VariableDeclaration", Visibility: "internal", StorageLocation: "default", Mutability: "mutable"}
+		if member.TypeName, err = p.typeName(); err != nil {
+			return nil, err
+		}
+		if member.Name, err = p.identifier(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(";"); err != nil {
+			return nil, err
+		}
+		node.Members = append(node.Members, member)
+	}
+	return node, nil
+}
+
+// enumDefinition parses an enum and its values.
+func (p *solidityParser) enumDefinition() (*ASTNode, error) {
+	p.next()
+	node := &ASTNode{ID: p.newID(), NodeType: "EnumDefinition"}
+	name, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	node.Name = name
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	for !p.accept("}") {
+		value, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		node.Members = append(node.Members, ASTNode{ID: p.newID(), NodeType: "EnumValue", Name: value})
+		if !p.accept(",") && !p.is("}") {
+			return nil, &parseError{token: p.peek(), expected: `"," or "}"`}
+		}
+	}
+	return node, nil
+}
+
+// valueTypeDefinition parses type Name is UnderlyingType;.
+func (p *solidityParser) valueTypeDefinition() (*ASTNode, error) {
+	p.next()
+	node := &ASTNode{ID: p.newID(), NodeType: "UserDefinedValueTypeDefinition"}
+	node.Name = p.next().Text
+	p.next()
+	var err error
+	if node.UnderlyingType, err = p.typeName(); err != nil {
+		return nil, err
+	}
+	return node, p.expect(";")
+}
+
+// usingForDirective parses using Library for Type; and using {f, g as +} for
+// Type global;.
+func (p *solidityParser) usingForDirective() (*ASTNode, error) {
+	p.next()
+	node := &ASTNode{ID: p.newID(), NodeType: "UsingForDirective"}
+	if p.accept("{") {
+		for !p.accept("}") {
+			name, err := p.identifierPath()
+			if err != nil {
+				return nil, err
+			}
+			path := &IdentifierPath{ID: p.newID(), NodeType: "IdentifierPath", Name: name}
+			entry := UsingForFunction{Function: path}
+			if p.accept("as") {
+				entry = UsingForFunction{Definition: path, Operator: p.next().Text}
+			}
+			node.FunctionList = append(node.FunctionList, entry)
+			if !p.accept(",") && !p.is("}") {
+				return nil, &parseError{token: p.peek(), expected: `"," or "}"`}
+			}
+		}
+	} else {
+		name, err := p.identifierPath()
+		if err != nil {
+			return nil, err
+		}
+		node.LibraryName = &TypeName{NodeType: "UserDefinedTypeName", Name: name}
+	}
+	if err := p.expect("for"); err != nil {
+		return nil, err
+	}
+	if !p.accept("*") {
+		var err error
+		if node.TypeName, err = p.typeName(); err != nil {
+			return nil, err
+		}
+	}
+	node.Global = p.accept("global")
+	return node, p.expect(";")
+}
+
+// stateVariable parses a state variable, or a file-level constant.
+func (p *solidityParser) stateVariable() (*ASTNode, error) {
+	node := &ASTNode{
+		ID:              p.newID(),
+		NodeType:        "VariableDeclaration",
+		StateVariable:   true,
+		Visibility:      "internal",
+		StorageLocation: "default",
+		Mutability:      "mutable",
+	}
+	var err error
+	if node.TypeName, err = p.typeName(); err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.is("public"), p.is("private"), p.is("internal"):
+			node.Visibility = p.next().Text
+		case p.accept("constant"):
+			node.Constant = true
+			node.Mutability = "constant"
+		case p.accept("immutable"):
+			node.Mutability = "immutable"
+		case p.accept("transient"):
+			node.StorageLocation = "transient"
+		case p.accept("override"):
+			if _, err := p.overrideSpecifier(); err != nil {
+				return nil, err
+			}
+		default:
+			if node.Name, err = p.identifier(); err != nil {
+				return nil, err
+			}
+			if p.accept("=") {
+				value, err := p.skipUntil(";")
+				if err != nil {
+					return nil, err
+				}
+				node.Value = value
+			}
+			return node, p.expect(";")
+		}
+	}
+}
+
+// parameterList parses a parenthesized list of parameters.
+func (p *solidityParser) parameterList() (*ParameterList, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	list := &ParameterList{}
+	for !p.accept(")") {
+		param := ASTNode{ID: p.newID(), NodeType: "VariableDeclaration", StorageLocation: "default", Mutability: "mutable"}
+		var err error
+		if param.TypeName, err = p.typeName(); err != nil {
+			return nil, err
+		}
+		for {
+			if p.is("memory") || p.is("storage") || p.is("calldata") {
+				param.StorageLocation = p.next().Text
+			} else if p.accept("indexed") {
+				indexed := true
+				param.Indexed = &indexed
+			} else {
+				break
+			}
+		}
+		if p.at(TokenIdentifier) {
+			param.Name = p.next().Text
+		}
+		list.Parameters = append(list.Parameters, param)
+		if !p.accept(",") && !p.is(")") {
+			return nil, &parseError{token: p.peek(), expected: `"," or ")"`}
+		}
+	}
+	return list, nil
+}
+
+// typeName parses a type name with its array suffixes.
+func (p *solidityParser) typeName() (*TypeName, error) {
+	var typeName *TypeName
+	var err error
+	switch {
+	case p.is("mapping"):
+		typeName, err = p.mappingTypeName()
+	case p.is("function"):
+		typeName, err = p.functionTypeName()
+	case p.at(TokenIdentifier):
+		start := p.peek().Start
+		name, err := p.identifierPath()
+		if err != nil {
+			return nil, err
+		}
+		if isElementaryType(name) {
+			typeName = elementaryTypeName(name)
+			if name == "address" && p.accept("payable") {
+				typeName.StateMutability = "payable"
+			}
+		} else {
+			// Located, so that an unresolved name can be reported
+			typeName = &TypeName{ID: p.newID(), Src: p.srcFrom(start), NodeType: "UserDefinedTypeName", Name: name}
+		}
+	default:
+		return nil, &parseError{token: p.peek(), expected: "type name"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	for p.is("[") {
+		p.next()
+		array := &TypeName{NodeType: "ArrayTypeName", BaseType: typeName}
+		if !p.is("]") {
+			length, err := p.skipUntil("]")
+			if err != nil {
+				return nil, err
+			}
+			array.Length = length
+		}
+		p.next()
+		typeName = array
+	}
+	return typeName, nil
+}
+
+// mappingTypeName parses mapping(KeyType [name] => ValueType [name]).
+func (p *solidityParser) mappingTypeName() (*TypeName, error) {
+	p.next()
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	mapping := &TypeName{NodeType: "Mapping"}
+	var err error
+	if mapping.KeyType, err = p.typeName(); err != nil {
+		return nil, err
+	}
+	if p.at(TokenIdentifier) {
+		p.next()
+	}
+	if err := p.expect("=>"); err != nil {
+		return nil, err
+	}
+	if mapping.ValueType, err = p.typeName(); err != nil {
+		return nil, err
+	}
+	if p.at(TokenIdentifier) {
+		p.next()
+	}
+	return mapping, p.expect(")")
+}
+
+// functionTypeName parses function (params) [visibility] [mutability]
+// [returns (params)].
+func (p *solidityParser) functionTypeName() (*TypeName, error) {
+	p.next()
+	typeName := &TypeName{NodeType: "FunctionTypeName", Visibility: "internal", StateMutability: "nonpayable"}
+	var err error
+	if typeName.ParameterTypes, err = p.parameterList(); err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.is("external"), p.is("internal"):
+			typeName.Visibility = p.next().Text
+		case p.is("pure"), p.is("view"), p.is("payable"):
+			typeName.StateMutability = p.next().Text
+		case p.is("returns"):
+			p.next()
+			if typeName.ReturnParameterTypes, err = p.parameterList(); err != nil {
+				return nil, err
+			}
+		default:
+			return typeName, nil
+		}
+	}
+}
+
+// identifierPath parses a possibly qualified name, such as Base or Lib.Type.
+func (p *solidityParser) identifierPath() (string, error) {
+	name, err := p.identifier()
+	if err != nil {
+		return "", err
+	}
+	for p.is(".") && p.peekAt(1).Kind == TokenIdentifier {
+		p.next()
+		name += "." + p.next().Text
+	}
+	return name, nil
+}
+
+// isElementaryType reports whether name is an elementary Solidity type. Sized
+// names must have a valid size: uint8 to uint256 in steps of 8, bytes1 to
+// bytes32, and fixedMxN with M like the integers and N up to 80.
+func isElementaryType(name string) bool {
+	switch name {
+	case "address", "bool", "string", "bytes", "byte", "uint", "int", "fixed", "ufixed":
+		return true
+	}
+	if size, ok := sizeSuffix(name, "bytes"); ok {
+		return size >= 1 && size <= 32
+	}
+	for _, prefix := range []string{"uint", "int"} {
+		if bits, ok := sizeSuffix(name, prefix); ok {
+			return isIntegerBits(bits)
+		}
+	}
+	for _, prefix := range []string{"ufixed", "fixed"} {
+		if size := strings.TrimPrefix(name, prefix); size != name {
+			m, n, found := strings.Cut(size, "x")
+			bits, err := strconv.Atoi(m)
+			decimals, err2 := strconv.Atoi(n)
+			return found && err == nil && err2 == nil && isIntegerBits(bits) && decimals >= 0 && decimals <= 80
+		}
+	}
+	return false
+}
+
+// sizeSuffix returns the size following prefix in name, such as 32 for bytes32.
+func sizeSuffix(name string, prefix string) (int, bool) {
+	size := strings.TrimPrefix(name, prefix)
+	if size == name || size == "" || strings.Trim(size, "0123456789") != "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(size)
+	return n, err == nil
+}
+
+// isIntegerBits reports whether bits is a valid integer width.
+func isIntegerBits(bits int) bool {
+	return bits >= 8 && bits <= 256 && bits%8 == 0
+}
+
+// unquote removes the quotes around a string literal.
+func unquote(literal string) string {
+	if len(literal) >= 2 {
+		return literal[1 : len(literal)-1]
+	}
+	return literal
+}
+
+// ResolveSourceUnits completes the source units parsed from Solidity source
+// code: contracts get their C3 linearization from the base names, and
+// user-defined type names are resolved to the struct, enum, user-defined value
+// type or contract they name. Names are looked up in the contract and its
+// bases first, then among file-level declarations and contracts of the units of
+// the same compilation: the Solidity files and artifacts of the data folder, or
+// the sources of a verified-source bundle. Names that match no declaration are
+// reported to diags.
+func ResolveSourceUnits(units map[string]*SourceUnit, diags *Diagnostics) {
+	var paths []string
+	for path := range units {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	// Contracts by compilation and name, the ones of the same unit taking
+	// precedence
+	byName := make(map[string]*Contract)
+	unitOf := make(map[*Contract]*SourceUnit)
+	for _, path := range paths {
+		for _, contract := range units[path].Contracts {
+			key := units[path].Compilation + ":" + contract.Name
+			if _, ok := byName[key]; !ok {
+				byName[key] = contract
+			}
+			unitOf[contract] = units[path]
+		}
+	}
+	lookupContract := func(unit *SourceUnit, name string) *Contract {
+		name = name[strings.LastIndex(name, ".")+1:]
+		for _, contract := range unit.Contracts {
+			if contract.Name == name {
+				return contract
+			}
+		}
+		return byName[unit.Compilation+":"+name]
+	}
+
+	linearizations := make(map[*Contract][]*Contract)
+	for _, path := range paths {
+		unit := units[path]
+		for _, contract := range unit.Contracts {
+			if contract.ASTFlavour != ASTSource {
+				continue
+			}
+			chain := linearize(contract, unitOf, lookupContract, linearizations, make(map[*Contract]bool), diags)
+			contract.LinearizedBaseContracts = nil
+			for _, base := range chain {
+				contract.LinearizedBaseContracts = append(contract.LinearizedBaseContracts, base.ID)
+			}
+		}
+	}
+
+	for _, path := range paths {
+		unit := units[path]
+		// Names of compiled and Vyper units are already resolved and left as
+		// they are
+		if unit.ASTFlavour != ASTSource {
+			continue
+		}
+		resolver := &sourceResolver{units: units, paths: paths, unit: unit, lookupContract: lookupContract, diags: diags.ForFile(path)}
+		resolver.resolveFunctions(unit.Functions)
+		for _, event := range unit.Events {
+			resolver.resolveParameters(event.Parameters)
+		}
+		resolver.resolveErrors(unit.Errors)
+		resolver.resolveVariables(unit.Constants)
+		resolver.resolveStructs(unit.Structs)
+		for _, contract := range unit.Contracts {
+			resolver.scope = linearizations[contract]
+			for _, special := range []*Function{contract.Constructor, contract.Fallback, contract.Receive} {
+				if special != nil {
+					resolver.resolveParameters(special.Parameters)
+					resolver.resolveParameters(special.ReturnParameters)
+				}
+			}
+			resolver.resolveFunctions(contract.Functions)
+			resolver.resolveErrors(contract.Errors)
+			for _, event := range contract.Events {
+				resolver.resolveParameters(event.Parameters)
+			}
+			for _, modifier := range contract.Modifiers {
+				resolver.resolveParameters(modifier.Parameters)
+			}
+			resolver.resolveVariables(contract.Variables)
+			resolver.resolveVariables(contract.Constants)
+			resolver.resolveVariables(contract.Mappings)
+			resolver.resolveStructs(contract.Structs)
+		}
+	}
+}
+
+// linearize returns the C3 linearization of a contract, most derived first.
+// Bases listed later in the inheritance list are the more derived ones.
+// Unknown bases are reported to diags and left out.
+func linearize(contract *Contract, unitOf map[*Contract]*SourceUnit, lookup func(*SourceUnit, string) *Contract, done map[*Contract][]*Contract, visiting map[*Contract]bool, diags *Diagnostics) []*Contract {
+	if chain, ok := done[contract]; ok {
+		return chain
+	}
+	if visiting[contract] {
+		return []*Contract{contract}
+	}
+	visiting[contract] = true
+
+	var sequences [][]*Contract
+	var direct []*Contract
+	for i := len(contract.Inherits) - 1; i >= 0; i-- {
+		base := lookup(unitOf[contract], contract.Inherits[i])
+		if base == nil && contract.ASTFlavour == ASTSource {
+			diags.ForFile(unitOf[contract].Path).Warnf(contract.ID, "", "unresolved base contract %s of %s", contract.Inherits[i], contract.Name)
+		}
+		if base == nil || base == contract {
+			continue
+		}
+		sequences = append(sequences, linearize(base, unitOf, lookup, done, visiting, diags))
+		direct = append(direct, base)
+	}
+	sequences = append(sequences, direct)
+
+	chain := []*Contract{contract}
+	for {
+		var candidate *Contract
+		for _, sequence := range sequences {
+			if len(sequence) == 0 {
+				continue
+			}
+			head := sequence[0]
+			inTail := false
+			for _, other := range sequences {
+				for _, c := range other[min(1, len(other)):] {
+					if c == head {
+						inTail = true
+					}
+				}
+			}
+			if !inTail {
+				candidate = head
+				break
+			}
+		}
+		if candidate == nil {
+			// Inconsistent hierarchy, keep the remaining bases in order
+			for _, sequence := range sequences {
+				for _, c := range sequence {
+					if !containsContract(chain, c) {
+						chain = append(chain, c)
+					}
+				}
+			}
+			break
+		}
+		chain = append(chain, candidate)
+		for i, sequence := range sequences {
+			if len(sequence) > 0 && sequence[0] == candidate {
+				sequences[i] = sequence[1:]
+			}
+		}
+		empty := true
+		for _, sequence := range sequences {
+			if len(sequence) > 0 {
+				empty = false
+			}
+		}
+		if empty {
+			break
+		}
+	}
+	done[contract] = chain
+	return chain
+}
+
+// containsContract reports whether chain holds contract.
+func containsContract(chain []*Contract, contract *Contract) bool {
+	for _, c := range chain {
+		if c == contract {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceResolver resolves the user-defined type names of a parsed source unit.
+type sourceResolver struct {
+	units          map[string]*SourceUnit
+	paths          []string
+	unit           *SourceUnit
+	scope          []*Contract // Linearization of the contract being resolved
+	lookupContract func(*SourceUnit, string) *Contract
+	diags          *Diagnostics
+}
+
+func (r *sourceResolver) resolveFunctions(functions []Function) {
+	for _, function := range functions {
+		r.resolveParameters(function.Parameters)
+		r.resolveParameters(function.ReturnParameters)
+	}
+}
+
+func (r *sourceResolver) resolveErrors(errors []Error) {
+	for _, customError := range errors {
+		r.resolveParameters(customError.Parameters)
+	}
+}
+
+func (r *sourceResolver) resolveParameters(params []Parameter) {
+	for _, param := range params {
+		r.resolveType(param.TypeInfo)
+	}
+}
+
+func (r *sourceResolver) resolveVariables(variables []Variable) {
+	for _, variable := range variables {
+		r.resolveType(variable.TypeInfo)
+	}
+}
+
+func (r *sourceResolver) resolveStructs(structs []Struct) {
+	for _, strct := range structs {
+		r.resolveVariables(strct.Members)
+	}
+}
+
+// resolveType sets the referenced declaration of the user-defined type names
+// found in a type.
+func (r *sourceResolver) resolveType(t *Type) {
+	if t == nil {
+		return
+	}
+	switch t.Kind {
+	case TypeArray:
+		r.resolveType(t.BaseType)
+	case TypeMapping:
+		r.resolveType(t.KeyType)
+		r.resolveType(t.ValueType)
+	case TypeFunction:
+		for _, param := range t.Parameters {
+			r.resolveType(param)
+		}
+		for _, param := range t.ReturnParameters {
+			r.resolveType(param)
+		}
+	case TypeUserDefined:
+		if t.ReferencedDeclaration == 0 {
+			id, kind := r.lookup(t.Name)
+			if id == 0 {
+				r.diags.Warnf(t.ID, t.Src, "unresolved type name %s", t.Name)
+				return
+			}
+			t.ReferencedDeclaration, t.DeclarationKind = id, kind
+		}
+	}
+}
+
+// lookup returns the ID and kind of the declaration a type name refers to.
+func (r *sourceResolver) lookup(name string) (int, string) {
+	// A qualified name is looked up in the contract it starts with
+	if i := strings.LastIndex(name, "."); i > 0 {
+		if contract := r.lookupContract(r.unit, name[:i]); contract != nil {
+			if id, kind := contractDeclaration(contract, name[i+1:]); id != 0 {
+				return id, kind
+			}
+		}
+		name = name[i+1:]
+	}
+	for _, contract := range r.scope {
+		if id, kind := contractDeclaration(contract, name); id != 0 {
+			return id, kind
+		}
+	}
+	if id, kind := unitDeclaration(r.unit, name); id != 0 {
+		return id, kind
+	}
+	if contract := r.lookupContract(r.unit, name); contract != nil {
+		return contract.ID, "contract"
+	}
+	for _, path := range r.paths {
+		// Node IDs of other compilations cannot be referred to
+		if r.units[path].Compilation != r.unit.Compilation {
+			continue
+		}
+		if id, kind := unitDeclaration(r.units[path], name); id != 0 {
+			return id, kind
+		}
+	}
+	return 0, ""
+}
+
+// contractDeclaration finds a type declared in a contract.
+func contractDeclaration(contract *Contract, name string) (int, string) {
+	return typeDeclaration(name, contract.Structs, contract.Enums, contract.ValueTypes)
+}
+
+// unitDeclaration finds a type declared at file level.
+func unitDeclaration(unit *SourceUnit, name string) (int, string) {
+	return typeDeclaration(name, unit.Structs, unit.Enums, unit.ValueTypes)
+}
+
+// typeDeclaration finds a struct, enum or user-defined value type by name.
+func typeDeclaration(name string, structs []Struct, enums []Enum, valueTypes []UserDefinedValueType) (int, string) {
+	for _, strct := range structs {
+		if strct.Name == name {
+			return strct.ID, "struct"
+		}
+	}
+	for _, enum := range enums {
+		if enum.Name == name {
+			return enum.ID, "enum"
+		}
+	}
+	for _, valueType := range valueTypes {
+		if valueType.Name == name {
+			return valueType.ID, "userDefinedValueType"
+		}
+	}
+	return 0, ""
+}
@@ -0,0 +1,447 @@
+// solidity_test.go
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// declarationRows renders the declarations of a contract, one per row.
+func declarationRows(contract *Contract) []string {
+	header := contract.Kind + " " + contract.Name
+	if contract.Abstract {
+		header = "abstract " + header
+	}
+	if len(contract.Inherits) > 0 {
+		header += " is " + strings.Join(contract.Inherits, ", ")
+	}
+	rows := []string{header}
+	for _, special := range []*Function{contract.Constructor, contract.Fallback, contract.Receive} {
+		if special != nil {
+			rows = append(rows, fmt.Sprintf("%s %s %s", special.Kind, special.Signature, special.StateMutability))
+		}
+	}
+	for _, function := range contract.Functions {
+		rows = append(rows, fmt.Sprintf("function %s %s %s %s", function.Signature, function.Visibility, function.StateMutability, function.Selector))
+	}
+	for _, getter := range contract.Getters {
+		rows = append(rows, fmt.Sprintf("getter %s %s", getter.Signature, getter.Selector))
+	}
+	for _, event := range contract.Events {
+		rows = append(rows, "event "+event.Signature)
+	}
+	for _, e := range contract.Errors {
+		rows = append(rows, "error "+e.Signature)
+	}
+	for _, modifier := range contract.Modifiers {
+		rows = append(rows, "modifier "+modifier.Name)
+	}
+	for _, variable := range contract.Variables {
+		rows = append(rows, fmt.Sprintf("variable %s %s %s", variable.Type, variable.Visibility, variable.Name))
+	}
+	for _, variable := range contract.Constants {
+		rows = append(rows, fmt.Sprintf("constant %s %s = %s", variable.Type, variable.Name, variable.Value))
+	}
+	for _, variable := range contract.Mappings {
+		rows = append(rows, fmt.Sprintf("mapping %s %s", variable.Type, variable.Name))
+	}
+	for _, s := range contract.Structs {
+		var members []string
+		for _, member := range s.Members {
+			members = append(members, member.Type+" "+member.Name)
+		}
+		rows = append(rows, fmt.Sprintf("struct %s {%s}", s.Name, strings.Join(members, "; ")))
+	}
+	for _, enum := range contract.Enums {
+		rows = append(rows, fmt.Sprintf("enum %s {%s}", enum.Name, strings.Join(enum.Values, ", ")))
+	}
+	for _, valueType := range contract.ValueTypes {
+		rows = append(rows, fmt.Sprintf("type %s is %s", valueType.Name, valueType.UnderlyingType))
+	}
+	return rows
+}
+
+func TestParseSolidityDeclarations(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		contract string
+		want     []string
+	}{
+		{
+			name:     "functions",
+			contract: "Token",
+			src: `contract Token {
+				function transfer(address to, uint256 amount) external returns (bool) { return true; }
+				function balanceOf(address) public view returns (uint256 balance) {}
+				function totalSupply() external pure returns (uint256);
+				function _burn(uint256 amount) internal {}
+			}`,
+			want: []string{
+				"contract Token",
+				"function transfer(address,uint256) external nonpayable a9059cbb",
+				"function balanceOf(address) public view 70a08231",
+				"function totalSupply() external pure 18160ddd",
+				"function _burn(uint256) internal nonpayable ",
+			},
+		},
+		{
+			name:     "type aliases",
+			contract: "Old",
+			src: `contract Old {
+				function g(byte a, byte[] b) external {}
+				function h(uint a, int b, address payable c) external {}
+			}`,
+			want: []string{
+				"contract Old",
+				"function g(bytes1,bytes1[]) external nonpayable eb418248",
+				"function h(uint256,int256,address) external nonpayable 70acb0c6",
+			},
+		},
+		{
+			name:     "special functions",
+			contract: "Vault",
+			src: `abstract contract Vault {
+				constructor(address owner) payable {}
+				fallback() external {}
+				receive() external payable {}
+			}`,
+			want: []string{
+				"abstract contract Vault",
+				"constructor (address) payable",
+				"fallback () nonpayable",
+				"receive () payable",
+			},
+		},
+		{
+			name:     "events, errors and modifiers",
+			contract: "Token",
+			src: `interface IToken {}
+			contract Token is IToken {
+				event Transfer(address indexed from, address indexed to, uint256 value);
+				error InsufficientBalance(uint256 available, uint256 required);
+				modifier onlyOwner() { _; }
+				modifier when(bool condition) { _; }
+			}`,
+			want: []string{
+				"contract Token is IToken",
+				"event Transfer(address,address,uint256)",
+				"error InsufficientBalance(uint256,uint256)",
+				"modifier onlyOwner",
+				"modifier when",
+			},
+		},
+		{
+			name:     "state variables",
+			contract: "Store",
+			src: `contract Store {
+				uint256 public total;
+				address private owner;
+				uint256 constant MAX = 10 ** 18;
+				bytes32 public constant ROLE = keccak256("ROLE");
+				mapping(address => mapping(address => uint256)) public allowance;
+				function (uint256) external returns (bool) callback;
+			}`,
+			want: []string{
+				"contract Store",
+				"getter total() 2ddbd13a",
+				"getter ROLE() 9d53fe2b",
+				"getter allowance(address,address) dd62ed3e",
+				"variable uint256 public total",
+				"variable address private owner",
+				"variable function internal callback",
+				"constant uint256 MAX = 10 ** 18",
+				`constant bytes32 ROLE = keccak256("ROLE")`,
+				"mapping mapping(address => mapping(address => uint256)) allowance",
+			},
+		},
+		{
+			name:     "user-defined types",
+			contract: "Book",
+			src: `contract Book {
+				struct Order { uint128 price; uint128 size; Side side; }
+				enum Side { Buy, Sell }
+				type Price is uint128;
+				function place(Order calldata order, Price limit) external {}
+				function side(uint256 id) external view returns (Side) {}
+			}`,
+			want: []string{
+				"contract Book",
+				"function place((uint128,uint128,uint8),uint128) external nonpayable fda0def3",
+				"function side(uint256) external view 7890e5da",
+				"struct Order {uint128 price; uint128 size; Side side}",
+				"enum Side {Buy, Sell}",
+				"type Price is uint128",
+			},
+		},
+		{
+			name:     "interfaces and libraries",
+			contract: "IERC165",
+			src: `interface IERC165 { function supportsInterface(bytes4 interfaceId) external view returns (bool); }
+			library Math { function max(uint256 a, uint256 b) internal pure returns (uint256) {} }`,
+			want: []string{
+				"interface IERC165",
+				"function supportsInterface(bytes4) external view 01ffc9a7",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contracts := parseContracts(t, map[string]string{"A.sol": test.src}, nil)
+			contract, err := LookupContract(contracts, test.contract)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := declarationRows(contract)
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("declarations =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestParseSolidityFreeFunctions(t *testing.T) {
+	ast, err := ParseSolidity("A.sol", `function freeFn(uint256 a) pure returns (uint256) { return a; }
+		contract A { function f() {} }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit, err := ExtractSourceUnit(ast, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		function   Function
+		visibility string
+		selector   string
+	}{
+		{unit.Functions[0], "internal", ""},
+		// Functions of contracts were public by default before 0.5
+		{unit.Contracts[0].Functions[0], "public", "26121ff0"},
+	}
+	for _, test := range tests {
+		if test.function.Visibility != test.visibility || test.function.Selector != test.selector {
+			t.Errorf("%s: visibility %q, selector %q, want %q, %q", test.function.Name, test.function.Visibility, test.function.Selector, test.visibility, test.selector)
+		}
+	}
+}
+
+func TestResolveSourceUnitsLinearization(t *testing.T) {
+	tests := []struct {
+		name     string
+		sources  map[string]string
+		contract string
+		want     []string
+	}{
+		{
+			name:     "no base",
+			sources:  map[string]string{"A.sol": `contract A {}`},
+			contract: "A",
+			want:     []string{"A"},
+		},
+		{
+			name:     "single base",
+			sources:  map[string]string{"A.sol": `contract A {} contract B is A {}`},
+			contract: "B",
+			want:     []string{"B", "A"},
+		},
+		{
+			name:     "diamond",
+			sources:  map[string]string{"A.sol": `contract A {} contract B is A {} contract C is A {} contract D is B, C {}`},
+			contract: "D",
+			want:     []string{"D", "C", "B", "A"},
+		},
+		{
+			name:     "the last base listed is the most derived",
+			sources:  map[string]string{"A.sol": `contract X {} contract A is X {} contract C is X, A {}`},
+			contract: "C",
+			want:     []string{"C", "A", "X"},
+		},
+		{
+			name: "shared base of several bases",
+			sources: map[string]string{"A.sol": `abstract contract Context {}
+				contract ERC20 is Context {} abstract contract Ownable is Context {}
+				interface IVotes {} contract Votes is Context, IVotes {}
+				contract Token is ERC20, Ownable, Votes {}`},
+			contract: "Token",
+			want:     []string{"Token", "Votes", "IVotes", "Ownable", "ERC20", "Context"},
+		},
+		{
+			name: "bases in other files",
+			sources: map[string]string{
+				"base/A.sol": `contract A {}`,
+				"base/B.sol": `import "./A.sol"; contract B is A {}`,
+				"C.sol":      `import {B} from "./base/B.sol"; import "./base/A.sol" as Lib; contract C is Lib.A, B {}`,
+			},
+			contract: "C",
+			want:     []string{"C", "B", "A"},
+		},
+		{
+			name:     "unresolved bases are left out",
+			sources:  map[string]string{"A.sol": `contract A {} contract B is Missing, A {}`},
+			contract: "B",
+			want:     []string{"B", "A"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contracts := parseContracts(t, test.sources, nil)
+			contract, err := LookupContract(contracts, test.contract)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(contract.Linearized, ", ") != strings.Join(test.want, ", ") {
+				t.Errorf("linearized = %q, want %q", contract.Linearized, test.want)
+			}
+		})
+	}
+}
+
+func TestResolveSourceUnitsDiagnostics(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "resolved",
+			src: `struct S { uint256 x; } type Price is uint128; interface I {}
+				contract A { enum E { One } function f(S memory s, Price p, E e, I i) external {} }`,
+		},
+		{
+			name: "unknown base",
+			src:  `contract A is Missing {}`,
+			want: []string{"warning: A.sol: unresolved base contract Missing of A"},
+		},
+		{
+			name: "unknown type name",
+			src:  `contract A { function f(Missing m) external {} }`,
+			want: []string{"warning: A.sol (24:7:-1): unresolved type name Missing"},
+		},
+		{
+			name: "Vyper and invalid sized type names",
+			src:  `contract A { decimal d; uint7 u; }`,
+			want: []string{
+				"warning: A.sol (13:7:-1): unresolved type name decimal",
+				"warning: A.sol (24:5:-1): unresolved type name uint7",
+			},
+		},
+		{
+			name: "names of another contract must be qualified",
+			src:  `contract A { struct S { uint256 x; } } contract B { S s; A.S t; }`,
+			want: []string{"warning: A.sol (52:1:-1): unresolved type name S"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			diags := NewDiagnostics()
+			parseContracts(t, map[string]string{"A.sol": test.src}, diags.ForFile("A.sol"))
+			var got []string
+			for _, diagnostic := range diags.All() {
+				// Synthetic node IDs depend on the path, only check they are set
+				if diagnostic.NodeID == 0 {
+					t.Errorf("%s: no node ID", diagnostic)
+				}
+				diagnostic.NodeID = 0
+				got = append(got, diagnostic.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("diagnostics = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestResolveSourceUnitsLeavesCompiledUnits(t *testing.T) {
+	ast, err := ParseSolidity("A.sol", `struct S { uint256 x; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := ExtractSourceUnit(ast, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A compiled unit declaring its own S, which solc already resolved
+	typ := &Type{Kind: TypeUserDefined, Name: "S", Display: "struct S", ReferencedDeclaration: 7, DeclarationKind: "struct"}
+	compiled := &SourceUnit{
+		Path:       "B.sol",
+		ASTFlavour: ASTCompact,
+		Contracts: []*Contract{{
+			ID:         9,
+			Name:       "B",
+			Kind:       "contract",
+			Inherits:   []string{"Unknown"},
+			ASTFlavour: ASTCompact,
+			Variables:  []Variable{{ID: 8, Name: "s", Type: "struct S", TypeInfo: typ}},
+		}},
+	}
+	diags := NewDiagnostics()
+	ResolveSourceUnits(map[string]*SourceUnit{"A.sol": source, "B.sol": compiled}, diags)
+	if typ.ReferencedDeclaration != 7 || typ.DeclarationKind != "struct" {
+		t.Errorf("compiled type resolved again to #%d %s", typ.ReferencedDeclaration, typ.DeclarationKind)
+	}
+	if len(diags.All()) != 0 {
+		t.Errorf("diagnostics = %v, want none", diags.All())
+	}
+}
+
+func TestParseSolidityErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`contract A {`, `1:13: expected`},
+		{`contract {}`, `1:10: expected identifier, found "{"`},
+		{"contract A {\n  function f(uint256 a external) {}\n}", `2:24: expected "," or ")", found "external"`},
+		{`contract A { string s = "unterminated; }`, `unterminated`},
+	}
+	for _, test := range tests {
+		_, err := ParseSolidity("A.sol", test.src)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("ParseSolidity(%q) error = %v, want %q", test.src, err, test.want)
+		}
+	}
+}
+
+func TestIsElementaryType(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"address", true},
+		{"bool", true},
+		{"string", true},
+		{"bytes", true},
+		{"byte", true},
+		{"uint", true},
+		{"int", true},
+		{"uint8", true},
+		{"int128", true},
+		{"uint256", true},
+		{"bytes1", true},
+		{"bytes32", true},
+		{"fixed", true},
+		{"ufixed128x18", true},
+		{"fixed8x0", true},
+		{"fixed256x80", true},
+		{"uint7", false},
+		{"uint0", false},
+		{"int264", false},
+		{"bytes0", false},
+		{"bytes33", false},
+		{"fixed128x81", false},
+		{"fixed7x1", false},
+		{"fixed128", false},
+		{"ufixedx18", false},
+		{"decimal", false},
+		{"interface", false},
+		{"uintX", false},
+		{"Token", false},
+	}
+	for _, test := range tests {
+		if got := isElementaryType(test.name); got != test.want {
+			t.Errorf("isElementaryType(%s) = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// standardjson.go
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// StandardJSONOutput is the output of solc --standard-json. Every source unit
+// and every contract of the compilation is in a single file.
+type StandardJSONOutput struct {
+	Sources   map[string]StandardJSONSource              `json:"sources"`
+	Contracts map[string]map[string]StandardJSONContract `json:"contracts"` // Keyed by source path, then contract name
+}
+
+// StandardJSONSource is a compiled source unit of a standard-JSON output.
+type StandardJSONSource struct {
+	ID        int `json:"id"`
+	AST       AST `json:"ast"`
+	LegacyAST AST `json:"legacyAST"` // Only output by solc 0.4
+}
+
+// StandardJSONContract holds the outputs selected for a contract.
+type StandardJSONContract struct {
+	ABI           json.RawMessage `json:"abi,omitempty"`
+	StorageLayout *StorageLayout  `json:"storageLayout,omitempty"`
+}
+
+// BuildInfo is a Hardhat or Foundry build-info file, which wraps the
+// standard-JSON input and output of a compilation.
+type BuildInfo struct {
+	ID              string             `json:"id"`
+	Format          string             `json:"_format"`
+	SolcVersion     string             `json:"solcVersion"`
+	SolcLongVersion string             `json:"solcLongVersion"`
+	Output          StandardJSONOutput `json:"output"`
+}
+
+// ExtractStandardJSONOutput extracts every source unit of a standard-JSON
+// output, with the ABI and storage layout of each contract. Sources that fail
+// to extract are reported to diags and left out.
+func ExtractStandardJSONOutput(output StandardJSONOutput, diags *Diagnostics) ([]*SourceUnit, error) {
+	var paths []string
+	for path := range output.Sources {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var units []*SourceUnit
+	for _, path := range paths {
+		source := output.Sources[path]
+		if len(source.AST.Nodes) == 0 {
+			source.AST = source.LegacyAST
+		}
+		if len(source.AST.Nodes) == 0 {
+			// Only sources compiled with the ast output selected have one
+			continue
+		}
+		if source.AST.AbsolutePath == "" {
+			source.AST.AbsolutePath = path
+		}
+		unit, err := ExtractSourceUnit(source.AST, diags)
+		if err != nil {
+			diags.Errorf(0, "", "source %s: %v", path, err)
+			continue
+		}
+		for name, compiled := range output.Contracts[path] {
+			attachCompilerOutput(unit, name, compiled.ABI, compiled.StorageLayout, diags)
+		}
+		units = append(units, unit)
+	}
+	if len(units) == 0 {
+		return nil, fmt.Errorf("no AST found in the compiler output")
+	}
+	return units, nil
+}
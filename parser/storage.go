@@ -0,0 +1,316 @@
+// storage.go
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StorageEntry is a state variable placed in contract storage.
+type StorageEntry struct {
+	ID       int // AST node ID of the variable
+	Label    string
+	Contract string // Name of the contract declaring the variable
+	Slot     int
+	Offset   int // Byte offset within the slot
+	Size     int // Number of bytes, a multiple of 32 for types spanning whole slots
+	Type     string
+}
+
+// StorageLayout is the storage layout solc reports when the artifact was built
+// with the storageLayout extra output.
+type StorageLayout struct {
+	Storage []StorageLayoutItem          `json:"storage"`
+	Types   map[string]StorageLayoutType `json:"types"`
+}
+
+// StorageLayoutItem is a state variable, or a struct member, of a solc storage layout.
+type StorageLayoutItem struct {
+	AstID    int    `json:"astId"`
+	Contract string `json:"contract"` // Qualified as sourcePath:Name
+	Label    string `json:"label"`
+	Offset   int    `json:"offset"`
+	Slot     string `json:"slot"`
+	Type     string `json:"type"` // Key into StorageLayout.Types
+}
+
+// StorageLayoutType describes a type referenced by a solc storage layout.
+type StorageLayoutType struct {
+	Encoding      string              `json:"encoding"` // inplace, mapping, dynamic_array or bytes
+	Label         string              `json:"label"`
+	NumberOfBytes string              `json:"numberOfBytes"`
+	Key           string              `json:"key,omitempty"`
+	Value         string              `json:"value,omitempty"`
+	Base          string              `json:"base,omitempty"`
+	Members       []StorageLayoutItem `json:"members,omitempty"`
+}
+
+// Entries converts the solc storage layout to storage entries. It returns no
+// entries when any item cannot be converted, rather than part of the layout.
+func (layout *StorageLayout) Entries() ([]StorageEntry, error) {
+	entries := []StorageEntry{}
+	for _, item := range layout.Storage {
+		slot, err := strconv.Atoi(item.Slot)
+		if err != nil {
+			return nil, fmt.Errorf("slot %s of %s is out of range", item.Slot, item.Label)
+		}
+		entry := StorageEntry{
+			ID:     item.AstID,
+			Label:  item.Label,
+			Slot:   slot,
+			Offset: item.Offset,
+			Type:   item.Type,
+		}
+		// Keep the contract name only, like the computed layout
+		entry.Contract = item.Contract[strings.LastIndex(item.Contract, ":")+1:]
+		if t, ok := layout.Types[item.Type]; ok {
+			entry.Type = t.Label
+			entry.Size, err = strconv.Atoi(t.NumberOfBytes)
+			if err != nil {
+				return nil, fmt.Errorf("size %s of %s is not a number", t.NumberOfBytes, item.Label)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// ContractStorageLayout returns the storage layout of a contract, preferring the
+// one reported by the compiler over the computed one. The boolean is true when
+// the layout comes from the compiler.
+func ContractStorageLayout(contract *Contract, contracts map[string]*Contract) ([]StorageEntry, bool, error) {
+	if contract.CompilerStorage != nil {
+		return contract.CompilerStorage, true, nil
+	}
+	layout, err := ComputeStorageLayout(contract, contracts)
+	return layout, false, err
+}
+
+// CheckStorageLayout compares the storage layout reported by the compiler with
+// the computed one, and describes every variable they disagree on. It returns
+// nothing when the artifact has no storage layout.
+func CheckStorageLayout(contract *Contract, contracts map[string]*Contract) []string {
+	if contract.CompilerStorage == nil {
+		return nil
+	}
+	computed, err := ComputeStorageLayout(contract, contracts)
+	if err != nil {
+		return []string{fmt.Sprintf("computed layout incomplete: %s", err)}
+	}
+	return CompareStorageLayouts(contract.CompilerStorage, computed)
+}
+
+// CompareStorageLayouts matches the entries of two layouts of the same build by
+// AST node ID and describes the differences in slot, offset and size.
+func CompareStorageLayouts(reported []StorageEntry, computed []StorageEntry) []string {
+	var discrepancies []string
+	byID := make(map[int]StorageEntry)
+	for _, entry := range computed {
+		byID[entry.ID] = entry
+	}
+	for _, entry := range reported {
+		other, ok := byID[entry.ID]
+		if !ok {
+			discrepancies = append(discrepancies, fmt.Sprintf("%s.%s is missing from the computed layout", entry.Contract, entry.Label))
+			continue
+		}
+		delete(byID, entry.ID)
+		if entry.Slot != other.Slot || entry.Offset != other.Offset {
+			discrepancies = append(discrepancies, fmt.Sprintf("%s.%s is at %d:%d, computed %d:%d", entry.Contract, entry.Label, entry.Slot, entry.Offset, other.Slot, other.Offset))
+		}
+		if entry.Size != other.Size {
+			discrepancies = append(discrepancies, fmt.Sprintf("%s.%s takes %d bytes, computed %d", entry.Contract, entry.Label, entry.Size, other.Size))
+		}
+	}
+	for _, entry := range computed {
+		if _, ok := byID[entry.ID]; ok {
+			discrepancies = append(discrepancies, fmt.Sprintf("%s.%s is missing from the compiler layout", entry.Contract, entry.Label))
+		}
+	}
+	return discrepancies
+}
+
+// ComputeStorageLayout assigns storage slots and offsets to the state variables
+// of a contract and its bases, following the solc packing rules: bases come
+// first in linearization order, value types are packed into a slot while they
+// fit, and structs, arrays and mappings start a new slot and end their last one.
+// Constants, immutables and transient variables take no storage. The layout
+// is not computed when a base contract is missing, since the slots of the
+// contract depend on the variables of all its bases.
+func ComputeStorageLayout(contract *Contract, contracts map[string]*Contract) ([]StorageEntry, error) {
+	if contract.ASTFlavour == ASTVyper {
+		return nil, fmt.Errorf("storage layout of Vyper contract %s is not computed", contract.Name)
+	}
+	chain, missing := linearizedContracts(contract, contracts)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("base contracts of %s not found: %s", contract.Name, strings.Join(missing, ", "))
+	}
+	var entries []StorageEntry
+	slot, offset := 0, 0
+	for i := len(chain) - 1; i >= 0; i-- {
+		for _, variable := range storageVariables(chain[i]) {
+			size, fullSlots, err := storageSize(variable.TypeInfo)
+			if err != nil {
+				return entries, fmt.Errorf("storage layout of %s.%s: %w", chain[i].Name, variable.Name, err)
+			}
+			slot, offset = place(slot, offset, size, fullSlots)
+			entries = append(entries, StorageEntry{
+				ID:       variable.ID,
+				Label:    variable.Name,
+				Contract: chain[i].Name,
+				Slot:     slot,
+				Offset:   offset,
+				Size:     size,
+				Type:     variable.Type,
+			})
+			slot, offset = advance(slot, offset, size, fullSlots)
+		}
+	}
+	return entries, nil
+}
+
+// storageVariables returns the state variables of a contract that live in
+// storage, in declaration order. Variables and mappings are kept in separate
+// lists, AST IDs restore the order they were declared in.
+func storageVariables(contract *Contract) []Variable {
+	var variables []Variable
+	for _, list := range [][]Variable{contract.Variables, contract.Mappings} {
+		for _, variable := range list {
+			if variable.Constant || variable.Mutability == "immutable" || variable.Mutability == "constant" || variable.StorageLocation == "transient" {
+				continue
+			}
+			variables = append(variables, variable)
+		}
+	}
+	sort.SliceStable(variables, func(i, j int) bool {
+		return variables[i].ID < variables[j].ID
+	})
+	return variables
+}
+
+// place returns the slot and offset an item of the given size starts at.
+func place(slot int, offset int, size int, fullSlots bool) (int, int) {
+	if offset > 0 && (fullSlots || offset+size > 32) {
+		return slot + 1, 0
+	}
+	return slot, offset
+}
+
+// advance returns the position following an item placed at slot and offset.
+func advance(slot int, offset int, size int, fullSlots bool) (int, int) {
+	if fullSlots {
+		return slot + size/32, 0
+	}
+	return slot, offset + size
+}
+
+// storageSize returns the number of bytes a type takes in storage, and whether
+// it spans whole slots instead of being packed with its neighbours.
+func storageSize(t *Type) (int, bool, error) {
+	if t == nil {
+		return 0, false, fmt.Errorf("unknown type")
+	}
+	switch t.Kind {
+	case TypeElementary:
+		return elementarySize(t.Name)
+	case TypeMapping:
+		return 32, true, nil
+	case TypeFunction:
+		if t.Visibility == "external" {
+			return 24, false, nil
+		}
+		return 8, false, nil
+	case TypeArray:
+		if t.Length == "" {
+			return 32, true, nil
+		}
+		length, err := strconv.Atoi(t.Length)
+		if err != nil {
+			return 0, false, fmt.Errorf("array length %s is not a literal and the compiler did not resolve it", t.Length)
+		}
+		size, fullSlots, err := storageSize(t.BaseType)
+		if err != nil {
+			return 0, false, err
+		}
+		var slots int
+		if fullSlots || size > 16 {
+			slots = length * ((size + 31) / 32)
+		} else {
+			perSlot := 32 / size
+			slots = (length + perSlot - 1) / perSlot
+		}
+		return slots * 32, true, nil
+	case TypeUserDefined:
+		switch t.DeclarationKind {
+		case "contract":
+			return 20, false, nil
+		case "enum":
+			if t.EnumValues > 256 {
+				return 2, false, nil
+			}
+			return 1, false, nil
+		case "userDefinedValueType":
+			if t.Underlying == nil {
+				return 0, false, fmt.Errorf("underlying type of %s not found", t.Name)
+			}
+			return storageSize(t.Underlying)
+		case "struct":
+			if t.Components == nil {
+				return 0, false, fmt.Errorf("struct %s not found", t.Name)
+			}
+			slot, offset := 0, 0
+			for _, component := range t.Components {
+				size, fullSlots, err := storageSize(component)
+				if err != nil {
+					return 0, false, err
+				}
+				slot, offset = place(slot, offset, size, fullSlots)
+				slot, offset = advance(slot, offset, size, fullSlots)
+			}
+			if offset > 0 {
+				slot++
+			}
+			return slot * 32, true, nil
+		}
+	}
+	return 0, false, fmt.Errorf("unsupported type %s", t.Display)
+}
+
+// elementarySize returns the storage size of an elementary type.
+func elementarySize(name string) (int, bool, error) {
+	switch {
+	case name == "bool", name == "byte":
+		return 1, false, nil
+	case name == "address", strings.HasPrefix(name, "address "):
+		return 20, false, nil
+	case name == "string", name == "bytes":
+		return 32, true, nil
+	case name == "uint", name == "int":
+		return 32, false, nil
+	case name == "fixed", name == "ufixed":
+		// Aliases of fixed128x18 and ufixed128x18
+		return 16, false, nil
+	case strings.HasPrefix(name, "uint"), strings.HasPrefix(name, "int"):
+		bits, err := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(name, "u"), "int"))
+		if err != nil {
+			return 0, false, fmt.Errorf("unsupported type %s", name)
+		}
+		return bits / 8, false, nil
+	case strings.HasPrefix(name, "bytes"):
+		size, err := strconv.Atoi(strings.TrimPrefix(name, "bytes"))
+		if err != nil {
+			return 0, false, fmt.Errorf("unsupported type %s", name)
+		}
+		return size, false, nil
+	case strings.HasPrefix(name, "fixed"), strings.HasPrefix(name, "ufixed"):
+		// fixedMxN takes M bits
+		bits, err := strconv.Atoi(strings.SplitN(strings.TrimPrefix(strings.TrimPrefix(name, "u"), "fixed"), "x", 2)[0])
+		if err != nil {
+			return 0, false, fmt.Errorf("unsupported type %s", name)
+		}
+		return bits / 8, false, nil
+	}
+	return 0, false, fmt.Errorf("unsupported type %s", name)
+}
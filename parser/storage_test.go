@@ -0,0 +1,301 @@
+// storage_test.go
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// parseContracts parses Solidity sources keyed by path and resolves them the
+// way a data folder holding them is.
+func parseContracts(t *testing.T, sources map[string]string, diags *Diagnostics) map[string]*Contract {
+	t.Helper()
+	units := make(map[string]*SourceUnit)
+	for path, src := range sources {
+		ast, err := ParseSolidity(path, src)
+		if err != nil {
+			t.Fatalf("parsing %s: %v", path, err)
+		}
+		unit, err := ExtractSourceUnit(ast, diags)
+		if err != nil {
+			t.Fatalf("extracting %s: %v", path, err)
+		}
+		units[path] = unit
+	}
+	resolveUnits(units, diags)
+	return CollectContracts(units)
+}
+
+// layoutRows renders storage entries as label slot:offset size.
+func layoutRows(entries []StorageEntry) []string {
+	var rows []string
+	for _, entry := range entries {
+		rows = append(rows, fmt.Sprintf("%s %d:%d %d", entry.Label, entry.Slot, entry.Offset, entry.Size))
+	}
+	return rows
+}
+
+func TestComputeStorageLayout(t *testing.T) {
+	tests := []struct {
+		name     string
+		sources  map[string]string
+		contract string
+		want     []string
+	}{
+		{
+			name: "value types are packed while they fit",
+			sources: map[string]string{"A.sol": `contract A {
+				uint128 a; uint128 b; uint256 c; address d; bool e; bytes4 f; uint64 g;
+			}`},
+			contract: "A",
+			want:     []string{"a 0:0 16", "b 0:16 16", "c 1:0 32", "d 2:0 20", "e 2:20 1", "f 2:21 4", "g 3:0 8"},
+		},
+		{
+			name: "structs start and end a slot",
+			sources: map[string]string{"A.sol": `contract A {
+				struct S { uint128 x; uint256 y; uint8 z; }
+				uint8 a; S s; uint8 b;
+			}`},
+			contract: "A",
+			want:     []string{"a 0:0 1", "s 1:0 96", "b 4:0 1"},
+		},
+		{
+			name: "nested structs are sized recursively",
+			sources: map[string]string{"A.sol": `
+				struct Inner { uint64 x; uint64 y; }
+				contract A { struct Outer { Inner i; uint8 z; } Outer o; uint8 b; }`},
+			contract: "A",
+			want:     []string{"o 0:0 64", "b 2:0 1"},
+		},
+		{
+			name: "fixed arrays pack their items",
+			sources: map[string]string{"A.sol": `contract A {
+				uint8[40] a; uint128[3] b; bytes32[2] c; address[2] d; uint8 e;
+			}`},
+			contract: "A",
+			want:     []string{"a 0:0 64", "b 2:0 64", "c 4:0 64", "d 6:0 64", "e 8:0 1"},
+		},
+		{
+			name: "mappings, dynamic arrays, bytes and strings take a whole slot",
+			sources: map[string]string{"A.sol": `contract A {
+				uint8 a; mapping(address => uint256) m; uint8 b; uint256[] d; bytes c; string s; uint8 e;
+			}`},
+			contract: "A",
+			want:     []string{"a 0:0 1", "m 1:0 32", "b 2:0 1", "d 3:0 32", "c 4:0 32", "s 5:0 32", "e 6:0 1"},
+		},
+		{
+			name: "storage gaps reserve whole slots",
+			sources: map[string]string{"A.sol": `contract A {
+				uint256 a; uint256[50] __gap; uint8 b;
+			}`},
+			contract: "A",
+			want:     []string{"a 0:0 32", "__gap 1:0 1600", "b 51:0 1"},
+		},
+		{
+			name: "enums, contracts, value types and fixed point numbers",
+			sources: map[string]string{"A.sol": `
+				interface I {}
+				type Price is uint96;
+				contract A { enum E { One, Two } E e; I i; Price p; fixed f; ufixed64x10 g; }`},
+			contract: "A",
+			want:     []string{"e 0:0 1", "i 0:1 20", "p 1:0 12", "f 1:12 16", "g 2:0 8"},
+		},
+		{
+			name: "function types",
+			sources: map[string]string{"A.sol": `contract A {
+				function (uint256) external returns (bool) callback; function () internal hook; uint8 a;
+			}`},
+			contract: "A",
+			want:     []string{"callback 0:0 24", "hook 0:24 8", "a 1:0 1"},
+		},
+		{
+			name: "constants, immutables and transient variables take no storage",
+			sources: map[string]string{"A.sol": `contract A {
+				uint256 constant C = 1; uint256 immutable I; uint8 a; uint256 transient t; uint8 b;
+			}`},
+			contract: "A",
+			want:     []string{"a 0:0 1", "b 0:1 1"},
+		},
+		{
+			name: "bases come first in linearization order",
+			sources: map[string]string{
+				"Base.sol": `contract Base { uint128 x; }`,
+				"Impl.sol": `import "./Base.sol";
+					contract Other { uint64 o; }
+					contract Impl is Base, Other { uint128 y; }`,
+			},
+			contract: "Impl",
+			want:     []string{"x 0:0 16", "o 0:16 8", "y 1:0 16"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contracts := parseContracts(t, test.sources, nil)
+			contract, err := LookupContract(contracts, test.contract)
+			if err != nil {
+				t.Fatal(err)
+			}
+			layout, err := ComputeStorageLayout(contract, contracts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := layoutRows(layout)
+			if strings.Join(got, ", ") != strings.Join(test.want, ", ") {
+				t.Errorf("layout = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestComputeStorageLayoutIncomplete(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources map[string]string
+		wantErr string
+	}{
+		{
+			name:    "missing base",
+			sources: map[string]string{"Impl.sol": `import "./Base.sol"; contract Impl is Base { uint256 a; }`},
+			wantErr: "base contracts of Impl not found: Base",
+		},
+		{
+			name:    "array length not resolved",
+			sources: map[string]string{"Impl.sol": `contract Impl { uint256 constant N = 50; uint256[N] __gap; }`},
+			wantErr: "array length N is not a literal",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contracts := parseContracts(t, test.sources, nil)
+			contract, err := LookupContract(contracts, "Impl")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = ComputeStorageLayout(contract, contracts)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("error = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestComputeStorageLayoutMissingCompiledBase(t *testing.T) {
+	contract := &Contract{ID: 3, Name: "Impl", Kind: "contract", LinearizedBaseContracts: []int{3, 2}}
+	contracts := map[string]*Contract{"Impl.sol:Impl": contract}
+	_, err := ComputeStorageLayout(contract, contracts)
+	if err == nil || !strings.Contains(err.Error(), "#2") {
+		t.Errorf("error = %v, want the missing base #2", err)
+	}
+}
+
+func TestArrayLength(t *testing.T) {
+	tests := []struct {
+		typeString string
+		want       string
+	}{
+		{"uint256[50]", "50"},
+		{"uint256[50] storage ref", "50"},
+		{"struct A.S storage ref[3][4]", "4"},
+		{"mapping(uint256 => uint256)[2]", "2"},
+		{"uint256[]", ""},
+		{"uint256", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := arrayLength(&TypeDescriptions{TypeString: test.typeString}); got != test.want {
+			t.Errorf("arrayLength(%q) = %q, want %q", test.typeString, got, test.want)
+		}
+	}
+}
+
+func TestStorageSizeOfResolvedArrayLength(t *testing.T) {
+	// uint256[N] with N a constant, as solc outputs it
+	typeName := &TypeName{
+		NodeType:         "ArrayTypeName",
+		BaseType:         &TypeName{NodeType: "ElementaryTypeName", Name: "uint256"},
+		Length:           map[string]interface{}{"nodeType": "Identifier", "name": "N"},
+		TypeDescriptions: &TypeDescriptions{TypeString: "uint256[5]"},
+	}
+	size, fullSlots, err := storageSize(ExtractType(typeName, nil))
+	if err != nil || size != 160 || !fullSlots {
+		t.Errorf("storageSize = %d, %v, %v, want 160, true, nil", size, fullSlots, err)
+	}
+}
+
+func TestElementarySize(t *testing.T) {
+	tests := []struct {
+		name string
+		want int
+	}{
+		{"bool", 1},
+		{"address", 20},
+		{"address payable", 20},
+		{"uint", 32},
+		{"int8", 1},
+		{"uint96", 12},
+		{"bytes1", 1},
+		{"bytes32", 32},
+		{"fixed", 16},
+		{"ufixed", 16},
+		{"fixed64x10", 8},
+		{"ufixed256x80", 32},
+	}
+	for _, test := range tests {
+		size, _, err := elementarySize(test.name)
+		if err != nil || size != test.want {
+			t.Errorf("elementarySize(%s) = %d, %v, want %d", test.name, size, err, test.want)
+		}
+	}
+}
+
+func TestAttachCompilerStorageLayout(t *testing.T) {
+	types := map[string]StorageLayoutType{
+		"t_uint256": {Encoding: "inplace", Label: "uint256", NumberOfBytes: "32"},
+		"t_address": {Encoding: "inplace", Label: "address", NumberOfBytes: "20"},
+	}
+	tests := []struct {
+		name    string
+		storage []StorageLayoutItem
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			storage: []StorageLayoutItem{
+				{Label: "a", Slot: "0", Type: "t_uint256", Contract: "src/A.sol:A"},
+				{Label: "b", Slot: "1", Type: "t_address", Contract: "src/A.sol:A"},
+			},
+			want: []string{"a 0:0 32", "b 1:0 20"},
+		},
+		{
+			// Contracts with a custom storage base, layout at 2**255
+			name: "slot out of range",
+			storage: []StorageLayoutItem{
+				{Label: "a", Slot: "0", Type: "t_uint256", Contract: "src/A.sol:A"},
+				{Label: "b", Slot: "57896044618658097711785492504343953926634992332820282019728792003956564819968", Type: "t_uint256", Contract: "src/A.sol:A"},
+			},
+			wantErr: "invalid storage layout of A: slot 578",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			unit := &SourceUnit{Contracts: []*Contract{{ID: 1, Name: "A"}}}
+			diags := NewDiagnostics()
+			attachCompilerOutput(unit, "A", nil, &StorageLayout{Storage: test.storage, Types: types}, diags.ForFile("A.sol"))
+			storage := unit.Contracts[0].CompilerStorage
+			if test.wantErr != "" {
+				if storage != nil {
+					t.Errorf("storage = %q, want none", layoutRows(storage))
+				}
+				if diags.Count(SeverityError) != 1 || !strings.Contains(diags.All()[0].Message, test.wantErr) {
+					t.Errorf("diagnostics = %v, want %q", diags.All(), test.wantErr)
+				}
+				return
+			}
+			if got := layoutRows(storage); strings.Join(got, ", ") != strings.Join(test.want, ", ") {
+				t.Errorf("storage = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
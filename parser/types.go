@@ -0,0 +1,266 @@
+// types.go
+package parser
+
+import (
+	"strings"
+)
+
+// Kinds of Type.
+const (
+	TypeElementary  = "elementary"
+	TypeArray       = "array"
+	TypeMapping     = "mapping"
+	TypeUserDefined = "userDefined"
+	TypeFunction    = "function"
+)
+
+// Type is the structured form of a TypeName, kept next to the display string
+// of variables and parameters.
+type Type struct {
+	ID                    int    // AST node ID of the type name
+	Src                   string // src range of the type name, empty when unknown
+	Kind                  string
+	Display               string  // Display string, as stored on variables and parameters
+	Name                  string  // Elementary type name, or name of the user-defined type
+	StateMutability       string  // "payable" for address payable, or the function type mutability
+	BaseType              *Type   // For arrays
+	Length                string  // For fixed-size arrays, empty for dynamic arrays
+	KeyType               *Type   // For mappings
+	ValueType             *Type   // For mappings
+	ReferencedDeclaration int     // For user-defined types
+	DeclarationKind       string  // For user-defined types: struct, enum, contract or userDefinedValueType
+	Visibility            string  // For function types
+	Parameters            []*Type // For function types
+	ReturnParameters      []*Type // For function types
+	Components            []*Type // Struct member types, filled by ResolveSignatures
+	Underlying            *Type   // Underlying type of a value type, filled by ResolveSignatures
+	EnumValues            int     // Number of values of an enum, filled by ResolveSignatures
+}
+
+// ExtractType converts a TypeName node into a Type.
+func ExtractType(typeName *TypeName, diags *Diagnostics) *Type {
+	if typeName == nil {
+		return nil
+	}
+	t := extractType(typeName, diags)
+	if t != nil {
+		t.ID, t.Src = typeName.ID, typeName.Src
+		t.Display = extractTypeName(typeName, diags)
+	}
+	return t
+}
+
+func extractType(typeName *TypeName, diags *Diagnostics) *Type {
+	switch typeName.NodeType {
+	case "ElementaryTypeName":
+		return &Type{
+			Kind:            TypeElementary,
+			Name:            typeName.Name,
+			StateMutability: typeName.StateMutability,
+		}
+	case "ArrayTypeName":
+		t := &Type{
+			Kind:     TypeArray,
+			BaseType: ExtractType(typeName.BaseType, diags),
+		}
+		if typeName.Length != nil {
+			// Constant expressions are only resolved in the type string
+			t.Length = arrayLength(typeName.TypeDescriptions)
+			if t.Length == "" {
+				t.Length = extractValue(typeName.Length, diags)
+			}
+		}
+		return t
+	case "Mapping":
+		return &Type{
+			Kind:      TypeMapping,
+			KeyType:   ExtractType(typeName.KeyType, diags),
+			ValueType: ExtractType(typeName.ValueType, diags),
+		}
+	case "UserDefinedTypeName":
+		t := &Type{
+			Kind:                  TypeUserDefined,
+			Name:                  typeName.Name,
+			ReferencedDeclaration: typeName.ReferencedDeclaration,
+			DeclarationKind:       declarationKind(typeName.TypeDescriptions),
+		}
+		if typeName.PathNode != nil {
+			if t.Name == "" {
+				t.Name = typeName.PathNode.Name
+			}
+			if typeName.PathNode.ReferencedDeclaration != 0 {
+				t.ReferencedDeclaration = typeName.PathNode.ReferencedDeclaration
+			}
+		}
+		return t
+	case "FunctionTypeName":
+		t := &Type{
+			Kind:            TypeFunction,
+			Visibility:      typeName.Visibility,
+			StateMutability: typeName.StateMutability,
+		}
+		if typeName.ParameterTypes != nil {
+			for _, param := range typeName.ParameterTypes.Parameters {
+				t.Parameters = append(t.Parameters, ExtractType(param.TypeName, diags))
+			}
+		}
+		if typeName.ReturnParameterTypes != nil {
+			for _, param := range typeName.ReturnParameterTypes.Parameters {
+				t.ReturnParameters = append(t.ReturnParameters, ExtractType(param.TypeName, diags))
+			}
+		}
+		return t
+	}
+	diags.Warnf(typeName.ID, typeName.Src, "unsupported type name %s", typeName.NodeType)
+	return nil
+}
+
+// arrayLength returns the length of a fixed-size array type as resolved by the
+// compiler, e.g. 5 for the type string "uint256[5] storage ref", or an empty
+// string when the type string does not give one.
+func arrayLength(descriptions *TypeDescriptions) string {
+	if descriptions == nil {
+		return ""
+	}
+	typeString := descriptions.TypeString
+	end := strings.LastIndex(typeString, "]")
+	start := strings.LastIndex(typeString[:max(end, 0)], "[")
+	if start < 0 || end < start+2 {
+		return ""
+	}
+	length := typeString[start+1 : end]
+	if strings.Trim(length, "0123456789") != "" {
+		return ""
+	}
+	return length
+}
+
+// declarationKind derives the kind of a user-defined type from its type
+// identifier, e.g. t_struct$_Info_$12_storage_ptr, or from the type string.
+func declarationKind(descriptions *TypeDescriptions) string {
+	if descriptions == nil {
+		return ""
+	}
+	for _, kind := range []string{"struct", "enum", "contract", "userDefinedValueType"} {
+		if strings.HasPrefix(descriptions.TypeIdentifier, "t_"+kind) {
+			return kind
+		}
+	}
+	if fields := strings.Fields(descriptions.TypeString); len(fields) > 1 {
+		switch fields[0] {
+		case "struct", "enum", "contract":
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+// Canonical renders the type as used in ABI signatures: structs become tuples,
+// contracts address, enums uint8 and value types their underlying type.
+// Mappings and function types have no ABI encoding and are rendered as is.
+func (t *Type) Canonical() string {
+	if t == nil {
+		return ""
+	}
+	switch t.Kind {
+	case TypeElementary:
+		return canonicalType(t.Name)
+	case TypeArray:
+		return t.BaseType.Canonical() + "[" + t.Length + "]"
+	case TypeMapping:
+		return "mapping(" + t.KeyType.Canonical() + " => " + t.ValueType.Canonical() + ")"
+	case TypeFunction:
+		return "function"
+	case TypeUserDefined:
+		switch t.DeclarationKind {
+		case "contract":
+			return "address"
+		case "enum":
+			return "uint8"
+		case "userDefinedValueType":
+			if t.Underlying != nil {
+				return t.Underlying.Canonical()
+			}
+		case "struct":
+			if t.Components != nil {
+				var components []string
+				for _, component := range t.Components {
+					components = append(components, component.Canonical())
+				}
+				return "(" + strings.Join(components, ",") + ")"
+			}
+		}
+		return t.Name
+	}
+	return ""
+}
+
+// forEachParameter calls fn on every parameter declared in the source units,
+// with the unit declaring it.
+func forEachParameter(units map[string]*SourceUnit, fn func(*SourceUnit, *Parameter)) {
+	var unit *SourceUnit
+	params := func(list []Parameter) {
+		for i := range list {
+			fn(unit, &list[i])
+		}
+	}
+	functions := func(list []Function) {
+		for _, function := range list {
+			params(function.Parameters)
+			params(function.ReturnParameters)
+		}
+	}
+	for _, unit = range units {
+		functions(unit.Functions)
+		for _, event := range unit.Events {
+			params(event.Parameters)
+		}
+		for _, customError := range unit.Errors {
+			params(customError.Parameters)
+		}
+		for _, contract := range unit.Contracts {
+			for _, special := range []*Function{contract.Constructor, contract.Fallback, contract.Receive} {
+				if special != nil {
+					params(special.Parameters)
+					params(special.ReturnParameters)
+				}
+			}
+			functions(contract.Functions)
+			for _, event := range contract.Events {
+				params(event.Parameters)
+			}
+			for _, customError := range contract.Errors {
+				params(customError.Parameters)
+			}
+			for _, modifier := range contract.Modifiers {
+				params(modifier.Parameters)
+			}
+		}
+	}
+}
+
+// forEachVariable calls fn on every variable and struct member declared in
+// the source units, with the unit declaring it.
+func forEachVariable(units map[string]*SourceUnit, fn func(*SourceUnit, *Variable)) {
+	var unit *SourceUnit
+	variables := func(list []Variable) {
+		for i := range list {
+			fn(unit, &list[i])
+		}
+	}
+	structs := func(list []Struct) {
+		for _, strct := range list {
+			variables(strct.Members)
+		}
+	}
+	for _, unit = range units {
+		variables(unit.Constants)
+		structs(unit.Structs)
+		for _, contract := range unit.Contracts {
+			variables(contract.Variables)
+			variables(contract.Constants)
+			variables(contract.Mappings)
+			structs(contract.Structs)
+		}
+	}
+}
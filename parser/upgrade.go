@@ -0,0 +1,187 @@
+// upgrade.go
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// StorageChange is a difference between the storage layouts of two builds of
+// the same contract.
+type StorageChange struct {
+	Kind     string // removed, retyped, shifted, reordered, inserted, added, gap shrunk or gap grown
+	Label    string
+	Message  string
+	Breaking bool // The new layout corrupts the storage written by the old one
+}
+
+// CompareStorageUpgrade checks whether a contract with the new storage layout
+// can be deployed behind a proxy holding the old layout. Variables are matched
+// by declaring contract and name. A variable may only be added in slots the old
+// layout did not use, either after its last variable or inside a __gap array,
+// which must then shrink so that the variables after it keep their slots.
+func CompareStorageUpgrade(old []StorageEntry, new []StorageEntry) []StorageChange {
+	var changes []StorageChange
+	newByKey := make(map[string]StorageEntry)
+	for _, entry := range new {
+		newByKey[storageKey(entry)] = entry
+	}
+	oldByKey := make(map[string]StorageEntry)
+	for _, entry := range old {
+		oldByKey[storageKey(entry)] = entry
+	}
+
+	lastPosition := -1
+	for _, entry := range old {
+		key := storageKey(entry)
+		other, ok := newByKey[key]
+		if !ok {
+			changes = append(changes, StorageChange{
+				Kind:     "removed",
+				Label:    key,
+				Message:  fmt.Sprintf("%s at %d:%d was removed", key, entry.Slot, entry.Offset),
+				Breaking: true,
+			})
+			continue
+		}
+		if isGap(entry) {
+			changes = append(changes, compareGaps(key, entry, other)...)
+			continue
+		}
+		if storageType(entry.Type) != storageType(other.Type) || entry.Size != other.Size {
+			changes = append(changes, StorageChange{
+				Kind:     "retyped",
+				Label:    key,
+				Message:  fmt.Sprintf("%s changed type from %s to %s", key, entry.Type, other.Type),
+				Breaking: true,
+			})
+		}
+		if entry.Slot != other.Slot || entry.Offset != other.Offset {
+			changes = append(changes, StorageChange{
+				Kind:     "shifted",
+				Label:    key,
+				Message:  fmt.Sprintf("%s moved from %d:%d to %d:%d", key, entry.Slot, entry.Offset, other.Slot, other.Offset),
+				Breaking: true,
+			})
+		}
+		position := other.Slot*32 + other.Offset
+		if position < lastPosition {
+			changes = append(changes, StorageChange{
+				Kind:     "reordered",
+				Label:    key,
+				Message:  fmt.Sprintf("%s now comes before variables it used to follow", key),
+				Breaking: true,
+			})
+		}
+		if position > lastPosition {
+			lastPosition = position
+		}
+	}
+
+	for _, entry := range new {
+		key := storageKey(entry)
+		if _, ok := oldByKey[key]; ok {
+			continue
+		}
+		if overlapped := overlappingEntry(entry, old); overlapped != "" {
+			changes = append(changes, StorageChange{
+				Kind:     "inserted",
+				Label:    key,
+				Message:  fmt.Sprintf("%s was inserted at %d:%d, over the storage of %s", key, entry.Slot, entry.Offset, overlapped),
+				Breaking: true,
+			})
+			continue
+		}
+		changes = append(changes, StorageChange{
+			Kind:    "added",
+			Label:   key,
+			Message: fmt.Sprintf("%s was added at %d:%d in unused storage", key, entry.Slot, entry.Offset),
+		})
+	}
+	return changes
+}
+
+// IsStorageCompatible reports whether none of the changes is breaking.
+func IsStorageCompatible(changes []StorageChange) bool {
+	for _, change := range changes {
+		if change.Breaking {
+			return false
+		}
+	}
+	return true
+}
+
+// compareGaps reports a resized __gap array. Shrinking the gap is how new
+// variables are inserted before it, which is safe as long as the gap still ends
+// in the same slot.
+func compareGaps(key string, old StorageEntry, new StorageEntry) []StorageChange {
+	if old.Size == new.Size && old.Slot == new.Slot {
+		return nil
+	}
+	oldEnd := old.Slot + old.Size/32
+	newEnd := new.Slot + new.Size/32
+	change := StorageChange{Label: key, Breaking: oldEnd != newEnd}
+	switch {
+	case new.Size < old.Size:
+		change.Kind = "gap shrunk"
+		change.Message = fmt.Sprintf("%s shrunk from %d to %d slots", key, old.Size/32, new.Size/32)
+	case new.Size > old.Size:
+		change.Kind = "gap grown"
+		change.Message = fmt.Sprintf("%s grew from %d to %d slots", key, old.Size/32, new.Size/32)
+	default:
+		change.Kind = "shifted"
+		change.Message = fmt.Sprintf("%s moved from slot %d to %d", key, old.Slot, new.Slot)
+	}
+	if change.Breaking {
+		change.Message += fmt.Sprintf(", it now ends at slot %d instead of %d", newEnd, oldEnd)
+	}
+	return []StorageChange{change}
+}
+
+// overlappingEntry returns the key of the first variable of the old layout,
+// other than a __gap array, sharing storage bytes with the entry.
+func overlappingEntry(entry StorageEntry, old []StorageEntry) string {
+	start := entry.Slot*32 + entry.Offset
+	end := start + entry.Size
+	for _, other := range old {
+		if isGap(other) {
+			continue
+		}
+		otherStart := other.Slot*32 + other.Offset
+		if start < otherStart+other.Size && otherStart < end {
+			return storageKey(other)
+		}
+	}
+	return ""
+}
+
+// Parts of a type name the compiler spells out and the source may not
+var (
+	aliasedType    = regexp.MustCompile(`\b(u?int|byte)\b`)
+	typeKeyword    = regexp.MustCompile(`\b(struct|enum|contract|interface) `)
+	typeQualifier  = regexp.MustCompile(`\b\w+\.`)
+	functionParams = regexp.MustCompile(`function \([^)]*\)( \w+)*( \([^)]*\))?`)
+)
+
+// storageType normalises a type name so that the layouts computed from the
+// source, which keep the names written there (uint, S), compare equal to the
+// ones reported by the compiler (uint256, struct A.S). Function types are only
+// compared by size.
+func storageType(typ string) string {
+	typ = strings.ReplaceAll(typ, "address payable", "address")
+	typ = functionParams.ReplaceAllString(typ, "function")
+	typ = typeKeyword.ReplaceAllString(typ, "")
+	typ = typeQualifier.ReplaceAllString(typ, "")
+	return aliasedType.ReplaceAllStringFunc(typ, canonicalType)
+}
+
+// isGap reports whether the entry is a storage gap reserved for upgrades.
+func isGap(entry StorageEntry) bool {
+	return strings.HasPrefix(entry.Label, "__gap")
+}
+
+// storageKey identifies a variable across builds.
+func storageKey(entry StorageEntry) string {
+	return entry.Contract + "." + entry.Label
+}
@@ -0,0 +1,286 @@
+// upgrade_test.go
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+// entry returns a storage entry of the Impl contract.
+func entry(label string, slot int, offset int, size int, typ string) StorageEntry {
+	return StorageEntry{Contract: "Impl", Label: label, Slot: slot, Offset: offset, Size: size, Type: typ}
+}
+
+// changeRows renders storage changes as kind label, marking breaking ones.
+func changeRows(changes []StorageChange) []string {
+	var rows []string
+	for _, change := range changes {
+		row := change.Kind + " " + change.Label
+		if change.Breaking {
+			row = "!" + row
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestCompareStorageUpgrade(t *testing.T) {
+	tests := []struct {
+		name string
+		old  []StorageEntry
+		new  []StorageEntry
+		want []string
+	}{
+		{
+			name: "unchanged",
+			old:  []StorageEntry{entry("a", 0, 0, 32, "uint256"), entry("b", 1, 0, 20, "address")},
+			new:  []StorageEntry{entry("a", 0, 0, 32, "uint256"), entry("b", 1, 0, 20, "address")},
+		},
+		{
+			name: "appended",
+			old:  []StorageEntry{entry("a", 0, 0, 32, "uint256")},
+			new:  []StorageEntry{entry("a", 0, 0, 32, "uint256"), entry("b", 1, 0, 32, "uint256")},
+			want: []string{"added Impl.b"},
+		},
+		{
+			name: "packed after the last variable",
+			old:  []StorageEntry{entry("a", 0, 0, 20, "address")},
+			new:  []StorageEntry{entry("a", 0, 0, 20, "address"), entry("b", 0, 20, 1, "bool")},
+			want: []string{"added Impl.b"},
+		},
+		{
+			name: "removed",
+			old:  []StorageEntry{entry("a", 0, 0, 32, "uint256"), entry("b", 1, 0, 32, "uint256")},
+			new:  []StorageEntry{entry("a", 0, 0, 32, "uint256")},
+			want: []string{"!removed Impl.b"},
+		},
+		{
+			name: "retyped",
+			old:  []StorageEntry{entry("a", 0, 0, 32, "uint256")},
+			new:  []StorageEntry{entry("a", 0, 0, 16, "uint128")},
+			want: []string{"!retyped Impl.a"},
+		},
+		{
+			name: "reordered",
+			old:  []StorageEntry{entry("a", 0, 0, 32, "uint256"), entry("b", 1, 0, 32, "uint256")},
+			new:  []StorageEntry{entry("b", 0, 0, 32, "uint256"), entry("a", 1, 0, 32, "uint256")},
+			want: []string{"!shifted Impl.a", "!shifted Impl.b", "!reordered Impl.b"},
+		},
+		{
+			name: "inserted before a variable",
+			old:  []StorageEntry{entry("a", 0, 0, 32, "uint256"), entry("b", 1, 0, 32, "uint256")},
+			new:  []StorageEntry{entry("a", 0, 0, 32, "uint256"), entry("x", 1, 0, 32, "uint256"), entry("b", 2, 0, 32, "uint256")},
+			want: []string{"!shifted Impl.b", "!inserted Impl.x"},
+		},
+		{
+			name: "inserted into a packed slot",
+			old:  []StorageEntry{entry("a", 0, 0, 1, "bool"), entry("b", 0, 1, 20, "address")},
+			new:  []StorageEntry{entry("a", 0, 0, 1, "bool"), entry("x", 0, 1, 1, "bool"), entry("b", 0, 2, 20, "address")},
+			want: []string{"!shifted Impl.b", "!inserted Impl.x"},
+		},
+		{
+			name: "gap shrunk by the variables inserted before it",
+			old:  []StorageEntry{entry("a", 0, 0, 32, "uint256"), entry("__gap", 1, 0, 50*32, "uint256[50]"), entry("c", 51, 0, 32, "uint256")},
+			new:  []StorageEntry{entry("a", 0, 0, 32, "uint256"), entry("x", 1, 0, 32, "uint256"), entry("y", 2, 0, 32, "uint256"), entry("__gap", 3, 0, 48*32, "uint256[48]"), entry("c", 51, 0, 32, "uint256")},
+			want: []string{"gap shrunk Impl.__gap", "added Impl.x", "added Impl.y"},
+		},
+		{
+			name: "gap shrunk too much",
+			old:  []StorageEntry{entry("__gap", 0, 0, 50*32, "uint256[50]"), entry("c", 50, 0, 32, "uint256")},
+			new:  []StorageEntry{entry("x", 0, 0, 32, "uint256"), entry("__gap", 1, 0, 48*32, "uint256[48]"), entry("c", 49, 0, 32, "uint256")},
+			want: []string{"!gap shrunk Impl.__gap", "!shifted Impl.c", "added Impl.x"},
+		},
+		{
+			name: "gap not shrunk",
+			old:  []StorageEntry{entry("__gap", 0, 0, 50*32, "uint256[50]"), entry("c", 50, 0, 32, "uint256")},
+			new:  []StorageEntry{entry("x", 0, 0, 32, "uint256"), entry("__gap", 1, 0, 50*32, "uint256[50]"), entry("c", 51, 0, 32, "uint256")},
+			want: []string{"!shifted Impl.__gap", "!shifted Impl.c", "added Impl.x"},
+		},
+		{
+			name: "gap grown",
+			old:  []StorageEntry{entry("__gap", 0, 0, 50*32, "uint256[50]"), entry("c", 50, 0, 32, "uint256")},
+			new:  []StorageEntry{entry("__gap", 0, 0, 51*32, "uint256[51]"), entry("c", 51, 0, 32, "uint256")},
+			want: []string{"!gap grown Impl.__gap", "!shifted Impl.c"},
+		},
+		{
+			name: "gap grown after a removed variable",
+			old:  []StorageEntry{entry("a", 0, 0, 32, "uint256"), entry("__gap", 1, 0, 49*32, "uint256[49]")},
+			new:  []StorageEntry{entry("__gap", 0, 0, 50*32, "uint256[50]")},
+			want: []string{"!removed Impl.a", "gap grown Impl.__gap"},
+		},
+		{
+			name: "variables of another contract are told apart",
+			old:  []StorageEntry{{Contract: "Base", Label: "a", Slot: 0, Size: 32, Type: "uint256"}},
+			new:  []StorageEntry{entry("a", 0, 0, 32, "uint256")},
+			want: []string{"!removed Base.a", "!inserted Impl.a"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changes := CompareStorageUpgrade(test.old, test.new)
+			got := changeRows(changes)
+			if strings.Join(got, ", ") != strings.Join(test.want, ", ") {
+				t.Errorf("changes = %q, want %q", got, test.want)
+			}
+			wantCompatible := !strings.Contains(strings.Join(test.want, ","), "!")
+			if IsStorageCompatible(changes) != wantCompatible {
+				t.Errorf("compatible = %v, want %v", !wantCompatible, wantCompatible)
+			}
+		})
+	}
+}
+
+func TestCompareStorageUpgradeOfSources(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want []string
+	}{
+		{
+			name: "variable inserted before the gap of a base",
+			old: `contract Base { uint256 a; uint256[49] __gap; }
+				contract Impl is Base { uint256 c; }`,
+			new: `contract Base { uint256 a; uint128 b; uint128 d; uint256[48] __gap; }
+				contract Impl is Base { uint256 c; }`,
+			want: []string{"gap shrunk Base.__gap", "added Base.b", "added Base.d"},
+		},
+		{
+			name: "base variable reordered",
+			old:  `contract Base { uint256 a; uint256 b; } contract Impl is Base {}`,
+			new:  `contract Base { uint256 b; uint256 a; } contract Impl is Base {}`,
+			want: []string{"!shifted Base.a", "!shifted Base.b", "!reordered Base.b"},
+		},
+		{
+			name: "bases swapped",
+			old: `contract A { uint256 a; } contract B { uint256 b; }
+				contract Impl is A, B { uint256 c; }`,
+			new: `contract A { uint256 a; } contract B { uint256 b; }
+				contract Impl is B, A { uint256 c; }`,
+			want: []string{"!shifted A.a", "!shifted B.b", "!reordered B.b"},
+		},
+		{
+			name: "variable repacked by a narrower type",
+			old:  `contract Impl { uint256 a; address b; }`,
+			new:  `contract Impl { uint96 a; address b; }`,
+			want: []string{"!retyped Impl.a", "!shifted Impl.b"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var layouts [2][]StorageEntry
+			for i, src := range []string{test.old, test.new} {
+				contracts := parseContracts(t, map[string]string{"Impl.sol": src}, nil)
+				contract, err := LookupContract(contracts, "Impl")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if layouts[i], err = ComputeStorageLayout(contract, contracts); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got := changeRows(CompareStorageUpgrade(layouts[0], layouts[1]))
+			if strings.Join(got, ", ") != strings.Join(test.want, ", ") {
+				t.Errorf("changes = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCompareStorageUpgradeAcrossLayoutSources(t *testing.T) {
+	// Layout solc reports for the old build
+	reported := &StorageLayout{
+		Storage: []StorageLayoutItem{
+			{Label: "total", Slot: "0", Type: "t_uint256", Contract: "src/Impl.sol:Impl"},
+			{Label: "owner", Slot: "1", Type: "t_address_payable", Contract: "src/Impl.sol:Impl"},
+			{Label: "side", Slot: "1", Offset: 20, Type: "t_enum(Side)1", Contract: "src/Impl.sol:Impl"},
+			{Label: "token", Slot: "2", Type: "t_contract(IToken)2", Contract: "src/Impl.sol:Impl"},
+			{Label: "order", Slot: "3", Type: "t_struct(Order)3_storage", Contract: "src/Impl.sol:Impl"},
+			{Label: "orders", Slot: "5", Type: "t_mapping(t_uint256,t_struct(Order)3_storage)", Contract: "src/Impl.sol:Impl"},
+			{Label: "flags", Slot: "6", Type: "t_array(t_bytes1)2_storage", Contract: "src/Impl.sol:Impl"},
+			{Label: "hook", Slot: "7", Type: "t_function_external_nonpayable(t_uint256)returns(t_bool)", Contract: "src/Impl.sol:Impl"},
+		},
+		Types: map[string]StorageLayoutType{
+			"t_uint256":                                     {Label: "uint256", NumberOfBytes: "32"},
+			"t_address_payable":                             {Label: "address payable", NumberOfBytes: "20"},
+			"t_enum(Side)1":                                 {Label: "enum Impl.Side", NumberOfBytes: "1"},
+			"t_contract(IToken)2":                           {Label: "contract IToken", NumberOfBytes: "20"},
+			"t_struct(Order)3_storage":                      {Label: "struct Impl.Order", NumberOfBytes: "64"},
+			"t_array(t_bytes1)2_storage":                    {Label: "bytes1[2]", NumberOfBytes: "32"},
+			"t_mapping(t_uint256,t_struct(Order)3_storage)": {Label: "mapping(uint256 => struct Impl.Order)", NumberOfBytes: "32"},
+			"t_function_external_nonpayable(t_uint256)returns(t_bool)": {Label: "function (uint256) external returns (bool)", NumberOfBytes: "24"},
+		},
+	}
+	old, err := reported.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{
+			name: "same declarations",
+			src: `interface IToken {}
+				contract Impl {
+					struct Order { uint a; uint b; }
+					enum Side { Buy, Sell }
+					uint total; address payable owner; Side side; IToken token; Order order;
+					mapping(uint => Order) orders; byte[2] flags; function (uint) external returns (bool) hook;
+				}`,
+		},
+		{
+			name: "retyped declarations",
+			src: `interface IToken {}
+				contract Impl {
+					struct Order { uint a; uint b; }
+					enum Side { Buy, Sell }
+					int total; address payable owner; Side side; IToken token; Order order;
+					mapping(int => Order) orders; byte[2] flags; function (uint) external returns (bool) hook;
+				}`,
+			want: []string{"!retyped Impl.total", "!retyped Impl.orders"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contracts := parseContracts(t, map[string]string{"src/Impl.sol": test.src}, nil)
+			contract, err := LookupContract(contracts, "Impl")
+			if err != nil {
+				t.Fatal(err)
+			}
+			computed, err := ComputeStorageLayout(contract, contracts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := changeRows(CompareStorageUpgrade(old, computed))
+			if strings.Join(got, ", ") != strings.Join(test.want, ", ") {
+				t.Errorf("changes = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestStorageType(t *testing.T) {
+	tests := []struct {
+		computed string
+		reported string
+	}{
+		{"uint", "uint256"},
+		{"int[]", "int256[]"},
+		{"byte[2]", "bytes1[2]"},
+		{"address payable", "address"},
+		{"S", "struct A.S"},
+		{"A.S", "struct A.S"},
+		{"E", "enum A.E"},
+		{"IERC20", "contract IERC20"},
+		{"mapping(uint => mapping(address => S))", "mapping(uint256 => mapping(address => struct A.S))"},
+		{"function", "function (uint256) external returns (bool)"},
+		{"function[2]", "function (uint256) external[2]"},
+	}
+	for _, test := range tests {
+		if storageType(test.computed) != storageType(test.reported) {
+			t.Errorf("storageType(%q) = %q, storageType(%q) = %q, want equal", test.computed, storageType(test.computed), test.reported, storageType(test.reported))
+		}
+	}
+}
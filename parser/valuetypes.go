@@ -0,0 +1,50 @@
+// valuetypes.go
+package parser
+
+import (
+	"fmt"
+)
+
+// ResolveValueTypes sets UnderlyingType on every parameter and variable whose
+// type is a user-defined value type declared in one of the source units.
+func ResolveValueTypes(units map[string]*SourceUnit) {
+	registry := newTypeRegistry(units)
+	forEachParameter(units, func(unit *SourceUnit, param *Parameter) {
+		if id := valueTypeReference(param.TypeInfo); id != 0 {
+			param.UnderlyingType = registry.valueTypes[declarationKey{unit.Compilation, id}].UnderlyingType
+		}
+	})
+	forEachVariable(units, func(unit *SourceUnit, variable *Variable) {
+		if id := valueTypeReference(variable.TypeInfo); id != 0 {
+			variable.UnderlyingType = registry.valueTypes[declarationKey{unit.Compilation, id}].UnderlyingType
+		}
+	})
+}
+
+// valueTypeReference returns the declaration ID of a user-defined value type,
+// or 0 for any other type.
+func valueTypeReference(t *Type) int {
+	if t == nil || t.DeclarationKind != "userDefinedValueType" {
+		return 0
+	}
+	return t.ReferencedDeclaration
+}
+
+// DisplayType returns the parameter type, followed by the underlying type of a
+// user-defined value type when showUnderlying is set.
+func (p Parameter) DisplayType(showUnderlying bool) string {
+	return displayType(p.Type, p.UnderlyingType, showUnderlying)
+}
+
+// DisplayType returns the variable type, followed by the underlying type of a
+// user-defined value type when showUnderlying is set.
+func (v Variable) DisplayType(showUnderlying bool) string {
+	return displayType(v.Type, v.UnderlyingType, showUnderlying)
+}
+
+func displayType(typ string, underlying string, showUnderlying bool) string {
+	if showUnderlying && underlying != "" {
+		return fmt.Sprintf("%s (%s)", typ, underlying)
+	}
+	return typ
+}
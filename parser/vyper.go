@@ -0,0 +1,579 @@
+// vyper.go
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+// ASTVyper is the flavour of ASTs converted from a Vyper module.
+const ASTVyper = "vyper"
+
+// VyperNode is a node of the Vyper AST, as output by vyper -f ast or in the
+// sources of a Vyper standard-JSON output. Only the fields describing
+// declarations and their types are decoded.
+type VyperNode struct {
+	ASTType       string          `json:"ast_type"`
+	NodeID        int             `json:"node_id"`
+	Name          string          `json:"name,omitempty"`
+	Path          string          `json:"path,omitempty"`
+	Identifier    string          `json:"id,omitempty"`    // For Name
+	Arg           string          `json:"arg,omitempty"`   // For arg
+	Attr          string          `json:"attr,omitempty"`  // For Attribute
+	Value         json.RawMessage `json:"value,omitempty"` // A node, or the value of a literal
+	Body          []VyperNode     `json:"body,omitempty"`
+	Target        *VyperNode      `json:"target,omitempty"`     // For AnnAssign and VariableDecl
+	Annotation    *VyperNode      `json:"annotation,omitempty"` // For AnnAssign, VariableDecl and arg
+	Func          *VyperNode      `json:"func,omitempty"`       // For Call
+	Args          json.RawMessage `json:"args,omitempty"`       // A list for Call, an arguments node for FunctionDef
+	Returns       *VyperNode      `json:"returns,omitempty"`
+	DecoratorList []VyperNode     `json:"decorator_list,omitempty"`
+	Slice         *VyperNode      `json:"slice,omitempty"`    // For Subscript
+	Elements      []VyperNode     `json:"elements,omitempty"` // For Tuple
+	Left          *VyperNode      `json:"left,omitempty"`     // For BinOp
+	Right         *VyperNode      `json:"right,omitempty"`    // For BinOp
+	Operand       *VyperNode      `json:"operand,omitempty"`  // For UnaryOp
+	Op            *VyperNode      `json:"op,omitempty"`
+	IsPublic      bool            `json:"is_public,omitempty"`    // For VariableDecl
+	IsConstant    bool            `json:"is_constant,omitempty"`  // For VariableDecl
+	IsImmutable   bool            `json:"is_immutable,omitempty"` // For VariableDecl
+	IsTransient   bool            `json:"is_transient,omitempty"` // For VariableDecl
+}
+
+// valueNode returns the value of the node when it is a node itself.
+func (node *VyperNode) valueNode() *VyperNode {
+	var value VyperNode
+	if json.Unmarshal(node.Value, &value) != nil || value.ASTType == "" {
+		return nil
+	}
+	return &value
+}
+
+// literal returns the value of a literal node as a string.
+func (node *VyperNode) literal() string {
+	var value interface{}
+	if json.Unmarshal(node.Value, &value) != nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// callArgs returns the arguments of a Call node.
+func (node *VyperNode) callArgs() []VyperNode {
+	var args []VyperNode
+	_ = json.Unmarshal(node.Args, &args)
+	return args
+}
+
+// functionArgs returns the arguments declared by a FunctionDef node.
+func (node *VyperNode) functionArgs() []VyperNode {
+	var arguments struct {
+		Args []VyperNode `json:"args"`
+	}
+	_ = json.Unmarshal(node.Args, &arguments)
+	return arguments.Args
+}
+
+// vyperConverter converts the declarations of a Vyper module to AST nodes.
+type vyperConverter struct {
+	idBase  int
+	structs map[string]int // Struct names to converted IDs
+	flags   map[string]bool
+}
+
+// NormalizeVyperAST converts a Vyper Module node into an AST holding one
+// contract for the module and one interface per interface definition, so that
+// Vyper contracts are extracted like Solidity ones. Node IDs are moved to a
+// negative range derived from the module path, as every Vyper module numbers
+// its nodes from 0.
+func NormalizeVyperAST(module VyperNode) AST {
+	path := module.Path
+	if path == "" {
+		path = module.Name
+	}
+	converter := &vyperConverter{
+		idBase:  syntheticIDBase(path),
+		structs: make(map[string]int),
+		flags:   make(map[string]bool),
+	}
+	for _, node := range module.Body {
+		switch node.ASTType {
+		case "StructDef":
+			converter.structs[node.Name] = converter.id(node.NodeID)
+		case "FlagDef", "EnumDef":
+			converter.flags[node.Name] = true
+		}
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	contract := ASTNode{
+		ID:           converter.id(module.NodeID),
+		NodeType:     "ContractDefinition",
+		Name:         name,
+		ContractKind: "contract",
+	}
+	contract.LinearizedBaseContracts = []int{contract.ID}
+	ast := AST{AbsolutePath: path, Flavour: ASTVyper}
+	for _, node := range module.Body {
+		switch node.ASTType {
+		case "InterfaceDef":
+			ast.Nodes = append(ast.Nodes, converter.interfaceDefinition(node))
+		case "ImplementsDecl":
+			if node.Annotation != nil {
+				contract.BaseContracts = append(contract.BaseContracts, BaseContract{
+					BaseName: BaseName{Name: node.Annotation.Identifier},
+				})
+			}
+		case "VariableDecl", "AnnAssign":
+			contract.Nodes = append(contract.Nodes, converter.variableDeclaration(node))
+		case "FunctionDef":
+			contract.Nodes = append(contract.Nodes, converter.functionDefinition(node))
+		case "EventDef":
+			event := ASTNode{
+				ID:         converter.id(node.NodeID),
+				NodeType:   "EventDefinition",
+				Name:       node.Name,
+				Parameters: &ParameterList{},
+			}
+			for _, field := range node.Body {
+				if field.ASTType == "AnnAssign" && field.Target != nil {
+					event.Parameters.Parameters = append(event.Parameters.Parameters, converter.eventParameter(field))
+				}
+			}
+			contract.Nodes = append(contract.Nodes, event)
+		case "StructDef":
+			strct := ASTNode{
+				ID:       converter.id(node.NodeID),
+				NodeType: "StructDefinition",
+				Name:     node.Name,
+			}
+			for _, field := range node.Body {
+				if field.ASTType == "AnnAssign" && field.Target != nil {
+					strct.Members = append(strct.Members, ASTNode{
+						ID:       converter.id(field.NodeID),
+						NodeType: "VariableDeclaration",
+						Name:     field.Target.Identifier,
+						TypeName: converter.typeName(field.Annotation),
+					})
+				}
+			}
+			contract.Nodes = append(contract.Nodes, strct)
+		case "FlagDef", "EnumDef":
+			enum := ASTNode{
+				ID:       converter.id(node.NodeID),
+				NodeType: "EnumDefinition",
+				Name:     node.Name,
+			}
+			for _, member := range node.Body {
+				if value := member.valueNode(); member.ASTType == "Expr" && value != nil {
+					enum.Members = append(enum.Members, ASTNode{
+						ID:       converter.id(member.NodeID),
+						NodeType: "EnumValue",
+						Name:     value.Identifier,
+					})
+				}
+			}
+			contract.Nodes = append(contract.Nodes, enum)
+		}
+	}
+	ast.Nodes = append(ast.Nodes, contract)
+	return ast
+}
+
+// id moves a Vyper node ID to the range of the module.
+func (converter *vyperConverter) id(nodeID int) int {
+	return syntheticID(converter.idBase, nodeID)
+}
+
+// syntheticIDBits is the number of bits left for the nodes of a file in the
+// synthetic node IDs.
+const syntheticIDBits = 20
+
+// syntheticIDBase returns the base of the node IDs given to the declarations of
+// a file that was not compiled by solc. Bases are derived from the file path
+// so that IDs stay unique across files and never collide with solc IDs. The
+// hash is bounded so that IDs fit in an int on 32-bit platforms too, where
+// fewer distinct bases are available.
+func syntheticIDBase(path string) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(path))
+	bases := uint64(math.MaxInt >> (syntheticIDBits + 1))
+	return int(uint64(hash.Sum32())%bases) << syntheticIDBits
+}
+
+// syntheticID returns the negative node ID of the nth node of a file. IDs grow
+// with n, so they still follow the declaration order.
+func syntheticID(base int, n int) int {
+	return n - base - 1<<syntheticIDBits
+}
+
+// interfaceDefinition converts an interface declared in a Vyper module.
+func (converter *vyperConverter) interfaceDefinition(node VyperNode) ASTNode {
+	iface := ASTNode{
+		ID:           converter.id(node.NodeID),
+		NodeType:     "ContractDefinition",
+		Name:         node.Name,
+		ContractKind: "interface",
+	}
+	iface.LinearizedBaseContracts = []int{iface.ID}
+	for _, member := range node.Body {
+		if member.ASTType != "FunctionDef" {
+			continue
+		}
+		function := converter.functionDefinition(member)
+		function.Visibility = "external"
+		// The body of an interface function is its mutability
+		for _, statement := range member.Body {
+			if value := statement.valueNode(); statement.ASTType == "Expr" && value != nil && value.Identifier != "" {
+				function.StateMutability = value.Identifier
+			}
+		}
+		iface.Nodes = append(iface.Nodes, function)
+	}
+	return iface
+}
+
+// variableDeclaration converts a storage variable, constant or immutable.
+// Older Vyper versions wrap the type in public(), constant() or immutable()
+// calls instead of setting flags on the declaration.
+func (converter *vyperConverter) variableDeclaration(node VyperNode) ASTNode {
+	variable := ASTNode{
+		ID:            converter.id(node.NodeID),
+		NodeType:      "VariableDeclaration",
+		StateVariable: true,
+		Visibility:    "internal",
+		Mutability:    "mutable",
+	}
+	if node.Target != nil {
+		variable.Name = node.Target.Identifier
+	}
+	public, constant, immutable, transient := node.IsPublic, node.IsConstant, node.IsImmutable, node.IsTransient
+	annotation := node.Annotation
+	for annotation != nil && annotation.ASTType == "Call" && annotation.Func != nil {
+		args := annotation.callArgs()
+		if len(args) != 1 {
+			break
+		}
+		switch annotation.Func.Identifier {
+		case "public":
+			public = true
+		case "constant":
+			constant = true
+		case "immutable":
+			immutable = true
+		case "transient":
+			transient = true
+		default:
+			args = nil
+		}
+		if args == nil {
+			break
+		}
+		annotation = &args[0]
+	}
+	variable.TypeName = converter.typeName(annotation)
+	if public {
+		variable.Visibility = "public"
+	}
+	switch {
+	case constant:
+		variable.Constant = true
+		variable.Mutability = "constant"
+	case immutable:
+		variable.Mutability = "immutable"
+	case transient:
+		variable.StorageLocation = "transient"
+	}
+	if value := node.valueNode(); value != nil {
+		expression := converter.expression(*value)
+		variable.Value = &expression
+	}
+	return variable
+}
+
+// functionDefinition converts a function, taking its visibility, mutability
+// and reentrancy lock from the decorators.
+func (converter *vyperConverter) functionDefinition(node VyperNode) ASTNode {
+	function := ASTNode{
+		ID:               converter.id(node.NodeID),
+		NodeType:         "FunctionDefinition",
+		Name:             node.Name,
+		Kind:             "function",
+		Visibility:       "internal",
+		StateMutability:  "nonpayable",
+		Parameters:       &ParameterList{},
+		ReturnParameters: &ParameterList{},
+	}
+	switch node.Name {
+	case "__init__":
+		function.Kind = "constructor"
+		function.Name = ""
+	case "__default__":
+		function.Kind = "fallback"
+		function.Name = ""
+	}
+	for _, decorator := range node.DecoratorList {
+		switch {
+		case decorator.ASTType == "Name":
+			switch decorator.Identifier {
+			case "external", "internal":
+				function.Visibility = decorator.Identifier
+			case "public", "deploy":
+				function.Visibility = "public"
+			case "view", "pure", "payable", "nonpayable":
+				function.StateMutability = decorator.Identifier
+			case "constant":
+				function.StateMutability = "view"
+			case "nonreentrant":
+				function.Modifiers = append(function.Modifiers, ModifierInvocation{ModifierName: ASTNode{Name: "nonreentrant"}})
+			}
+		case decorator.ASTType == "Call" && decorator.Func != nil && decorator.Func.Identifier == "nonreentrant":
+			name := "nonreentrant"
+			if args := decorator.callArgs(); len(args) > 0 {
+				name = fmt.Sprintf("nonreentrant(%s)", args[0].literal())
+			}
+			function.Modifiers = append(function.Modifiers, ModifierInvocation{ModifierName: ASTNode{Name: name}})
+		}
+	}
+	for _, arg := range node.functionArgs() {
+		function.Parameters.Parameters = append(function.Parameters.Parameters, ASTNode{
+			ID:       converter.id(arg.NodeID),
+			NodeType: "VariableDeclaration",
+			Name:     arg.Arg,
+			TypeName: converter.typeName(arg.Annotation),
+		})
+	}
+	if node.Returns != nil {
+		returns := []VyperNode{*node.Returns}
+		if node.Returns.ASTType == "Tuple" {
+			returns = node.Returns.Elements
+		}
+		for _, ret := range returns {
+			function.ReturnParameters.Parameters = append(function.ReturnParameters.Parameters, ASTNode{
+				ID:       converter.id(ret.NodeID),
+				NodeType: "VariableDeclaration",
+				TypeName: converter.typeName(&ret),
+			})
+		}
+	}
+	return function
+}
+
+// eventParameter converts an event field, indexed when wrapped in indexed().
+func (converter *vyperConverter) eventParameter(field VyperNode) ASTNode {
+	indexed := false
+	annotation := field.Annotation
+	if annotation != nil && annotation.ASTType == "Call" && annotation.Func != nil && annotation.Func.Identifier == "indexed" {
+		if args := annotation.callArgs(); len(args) == 1 {
+			indexed = true
+			annotation = &args[0]
+		}
+	}
+	return ASTNode{
+		ID:       converter.id(field.NodeID),
+		NodeType: "VariableDeclaration",
+		Name:     field.Target.Identifier,
+		TypeName: converter.typeName(annotation),
+		Indexed:  &indexed,
+	}
+}
+
+// typeName converts a Vyper type annotation to the Solidity type with the
+// same ABI encoding. Flags are encoded as uint256 and interfaces as addresses.
+func (converter *vyperConverter) typeName(node *VyperNode) *TypeName {
+	if node == nil {
+		return nil
+	}
+	switch node.ASTType {
+	case "Name":
+		if id, ok := converter.structs[node.Identifier]; ok {
+			return &TypeName{
+				NodeType:              "UserDefinedTypeName",
+				Name:                  node.Identifier,
+				ReferencedDeclaration: id,
+				TypeDescriptions:      &TypeDescriptions{TypeString: "struct " + node.Identifier},
+			}
+		}
+		if converter.flags[node.Identifier] {
+			return elementaryTypeName("uint256")
+		}
+		if name := vyperElementaryType(node.Identifier); name != "" {
+			return elementaryTypeName(name)
+		}
+		// Any other name is an interface, declared or imported
+		return &TypeName{
+			NodeType:         "UserDefinedTypeName",
+			Name:             node.Identifier,
+			TypeDescriptions: &TypeDescriptions{TypeString: "contract " + node.Identifier},
+		}
+	case "Attribute":
+		// An interface imported from a module, such as IERC20 in ierc20.IERC20
+		return &TypeName{
+			NodeType:         "UserDefinedTypeName",
+			Name:             node.Attr,
+			TypeDescriptions: &TypeDescriptions{TypeString: "contract " + node.Attr},
+		}
+	case "Subscript":
+		if node.Slice == nil {
+			return nil
+		}
+		slice := node.Slice
+		if slice.ASTType == "Index" {
+			if value := slice.valueNode(); value != nil {
+				slice = value
+			}
+		}
+		base := node.valueNode()
+		if base == nil {
+			return nil
+		}
+		switch base.Identifier {
+		case "HashMap":
+			if len(slice.Elements) != 2 {
+				return nil
+			}
+			return &TypeName{
+				NodeType:  "Mapping",
+				KeyType:   converter.typeName(&slice.Elements[0]),
+				ValueType: converter.typeName(&slice.Elements[1]),
+			}
+		case "DynArray":
+			if len(slice.Elements) != 2 {
+				return nil
+			}
+			return &TypeName{
+				NodeType: "ArrayTypeName",
+				BaseType: converter.typeName(&slice.Elements[0]),
+			}
+		case "String":
+			return elementaryTypeName("string")
+		case "Bytes":
+			return elementaryTypeName("bytes")
+		}
+		return &TypeName{
+			NodeType: "ArrayTypeName",
+			BaseType: converter.typeName(base),
+			Length:   slice.literal(),
+		}
+	}
+	return nil
+}
+
+// expression converts the expression initialising a constant.
+func (converter *vyperConverter) expression(node VyperNode) ASTNode {
+	expression := ASTNode{
+		ID:       converter.id(node.NodeID),
+		NodeType: "Literal",
+	}
+	switch node.ASTType {
+	case "Int", "Decimal", "Hex", "Str", "Bytes", "HexBytes", "NameConstant":
+		expression.Value = node.literal()
+	case "Name":
+		expression.NodeType = "Identifier"
+		expression.Name = node.Identifier
+	case "UnaryOp":
+		expression.NodeType = "UnaryOperation"
+		expression.Operator = vyperOperator(node.Op)
+		if node.Operand != nil {
+			operand := converter.expression(*node.Operand)
+			expression.SubExpression = &operand
+		}
+	case "BinOp":
+		expression.NodeType = "BinaryOperation"
+		expression.Operator = vyperOperator(node.Op)
+		if node.Left != nil && node.Right != nil {
+			left := converter.expression(*node.Left)
+			right := converter.expression(*node.Right)
+			expression.LeftExpression = &left
+			expression.RightExpression = &right
+		}
+	case "Call":
+		expression.NodeType = "FunctionCall"
+		if node.Func != nil {
+			function := converter.expression(*node.Func)
+			expression.Expression = &function
+		}
+		for _, arg := range node.callArgs() {
+			argument := converter.expression(arg)
+			expression.Arguments = append(expression.Arguments, &argument)
+		}
+	case "Attribute":
+		expression.NodeType = "Identifier"
+		if value := node.valueNode(); value != nil && value.Identifier != "" {
+			expression.Name = value.Identifier + "." + node.Attr
+		} else {
+			expression.Name = node.Attr
+		}
+	default:
+		expression.Value = node.ASTType
+	}
+	return expression
+}
+
+// elementaryTypeName returns the type name of an elementary type.
+func elementaryTypeName(name string) *TypeName {
+	return &TypeName{
+		NodeType:         "ElementaryTypeName",
+		Name:             name,
+		TypeDescriptions: &TypeDescriptions{TypeString: name},
+	}
+}
+
+// vyperElementaryType returns the Solidity name of a Vyper elementary type, or
+// "" when the name is not one.
+func vyperElementaryType(name string) string {
+	switch {
+	case name == "decimal":
+		return "fixed168x10"
+	case name == "address", name == "bool", name == "bytes":
+		return name
+	}
+	// Sized types, such as uint256 or bytes32
+	for _, prefix := range []string{"uint", "int", "bytes"} {
+		size := strings.TrimPrefix(name, prefix)
+		if size != name && size != "" && strings.Trim(size, "0123456789") == "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// vyperOperator returns the Solidity operator of a Vyper operator node.
+func vyperOperator(op *VyperNode) string {
+	if op == nil {
+		return ""
+	}
+	switch op.ASTType {
+	case "Add", "UAdd":
+		return "+"
+	case "Sub", "USub":
+		return "-"
+	case "Mult":
+		return "*"
+	case "Div", "FloorDiv":
+		return "/"
+	case "Mod":
+		return "%"
+	case "Pow":
+		return "**"
+	case "LShift":
+		return "<<"
+	case "RShift":
+		return ">>"
+	case "BitAnd":
+		return "&"
+	case "BitOr":
+		return "|"
+	case "BitXor":
+		return "^"
+	case "Invert":
+		return "~"
+	case "Not":
+		return "!"
+	}
+	return op.ASTType
+}
@@ -0,0 +1,120 @@
+// vyper_test.go
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+// vyperVault is the Vyper 0.4 AST of:
+//
+//	interface Oracle:
+//	    def price(a: address) -> uint256: view
+//	event Deposit:
+//	    sender: indexed(address)
+//	    amount: uint256
+//	struct Info:
+//	    a: uint128
+//	    b: address
+//	flag Roles:
+//	    ADMIN
+//	    USER
+//	MAX: constant(uint256) = 10 ** 18
+//	owner: public(address)
+//	balanceOf: public(HashMap[address, uint256])
+//	infos: public(HashMap[uint256, Info])
+//	holders: public(DynArray[address, 10])
+//	slots: uint256[3]
+//	oracle: public(immutable(Oracle))
+//	@deploy
+//	def __init__(o: Oracle): pass
+//	@external
+//	@payable
+//	@nonreentrant
+//	def deposit(to: address, amount: uint256, roles: Roles) -> bool: pass
+//	@external
+//	@view
+//	def info(id: uint256) -> Info: pass
+//	@internal
+//	def _check(b: Bytes[32]): pass
+//	@external
+//	def __default__(): pass
+//
+// owner is declared the pre-0.4 way, wrapped in a public() call.
+const vyperVault = `{"contract_name": "Vault", "ast": {"ast_type": "Module", "node_id": 115, "name": "Vault.vy", "path": "contracts/Vault.vy", "body": [
+	{"ast_type": "InterfaceDef", "node_id": 8, "name": "Oracle", "body": [{"ast_type": "FunctionDef", "node_id": 7, "name": "price", "args": {"ast_type": "arguments", "node_id": 6, "args": [{"ast_type": "arg", "node_id": 2, "arg": "a", "annotation": {"ast_type": "Name", "node_id": 1, "id": "address"}}], "defaults": []}, "returns": {"ast_type": "Name", "node_id": 3, "id": "uint256"}, "decorator_list": [], "body": [{"ast_type": "Expr", "node_id": 5, "value": {"ast_type": "Name", "node_id": 4, "id": "view"}}]}]},
+	{"ast_type": "EventDef", "node_id": 17, "name": "Deposit", "body": [{"ast_type": "AnnAssign", "node_id": 13, "target": {"ast_type": "Name", "node_id": 12, "id": "sender"}, "annotation": {"ast_type": "Call", "node_id": 11, "func": {"ast_type": "Name", "node_id": 10, "id": "indexed"}, "args": [{"ast_type": "Name", "node_id": 9, "id": "address"}], "keywords": []}}, {"ast_type": "AnnAssign", "node_id": 16, "target": {"ast_type": "Name", "node_id": 15, "id": "amount"}, "annotation": {"ast_type": "Name", "node_id": 14, "id": "uint256"}}]},
+	{"ast_type": "StructDef", "node_id": 24, "name": "Info", "body": [{"ast_type": "AnnAssign", "node_id": 20, "target": {"ast_type": "Name", "node_id": 19, "id": "a"}, "annotation": {"ast_type": "Name", "node_id": 18, "id": "uint128"}}, {"ast_type": "AnnAssign", "node_id": 23, "target": {"ast_type": "Name", "node_id": 22, "id": "b"}, "annotation": {"ast_type": "Name", "node_id": 21, "id": "address"}}]},
+	{"ast_type": "FlagDef", "node_id": 29, "name": "Roles", "body": [{"ast_type": "Expr", "node_id": 26, "value": {"ast_type": "Name", "node_id": 25, "id": "ADMIN"}}, {"ast_type": "Expr", "node_id": 28, "value": {"ast_type": "Name", "node_id": 27, "id": "USER"}}]},
+	{"ast_type": "VariableDecl", "node_id": 36, "target": {"ast_type": "Name", "node_id": 30, "id": "MAX"}, "annotation": {"ast_type": "Name", "node_id": 31, "id": "uint256"}, "value": {"ast_type": "BinOp", "node_id": 35, "left": {"ast_type": "Int", "node_id": 32, "value": 10}, "op": {"ast_type": "Pow", "node_id": 33}, "right": {"ast_type": "Int", "node_id": 34, "value": 18}}, "is_constant": true},
+	{"ast_type": "VariableDecl", "node_id": 41, "target": {"ast_type": "Name", "node_id": 37, "id": "owner"}, "annotation": {"ast_type": "Call", "node_id": 40, "func": {"ast_type": "Name", "node_id": 39, "id": "public"}, "args": [{"ast_type": "Name", "node_id": 38, "id": "address"}], "keywords": []}},
+	{"ast_type": "VariableDecl", "node_id": 49, "target": {"ast_type": "Name", "node_id": 42, "id": "balanceOf"}, "annotation": {"ast_type": "Subscript", "node_id": 48, "value": {"ast_type": "Name", "node_id": 46, "id": "HashMap"}, "slice": {"ast_type": "Index", "node_id": 47, "value": {"ast_type": "Tuple", "node_id": 45, "elements": [{"ast_type": "Name", "node_id": 43, "id": "address"}, {"ast_type": "Name", "node_id": 44, "id": "uint256"}]}}}, "is_public": true},
+	{"ast_type": "VariableDecl", "node_id": 57, "target": {"ast_type": "Name", "node_id": 50, "id": "infos"}, "annotation": {"ast_type": "Subscript", "node_id": 56, "value": {"ast_type": "Name", "node_id": 54, "id": "HashMap"}, "slice": {"ast_type": "Index", "node_id": 55, "value": {"ast_type": "Tuple", "node_id": 53, "elements": [{"ast_type": "Name", "node_id": 51, "id": "uint256"}, {"ast_type": "Name", "node_id": 52, "id": "Info"}]}}}, "is_public": true},
+	{"ast_type": "VariableDecl", "node_id": 65, "target": {"ast_type": "Name", "node_id": 58, "id": "holders"}, "annotation": {"ast_type": "Subscript", "node_id": 64, "value": {"ast_type": "Name", "node_id": 62, "id": "DynArray"}, "slice": {"ast_type": "Index", "node_id": 63, "value": {"ast_type": "Tuple", "node_id": 61, "elements": [{"ast_type": "Name", "node_id": 59, "id": "address"}, {"ast_type": "Int", "node_id": 60, "value": 10}]}}}, "is_public": true},
+	{"ast_type": "VariableDecl", "node_id": 71, "target": {"ast_type": "Name", "node_id": 66, "id": "slots"}, "annotation": {"ast_type": "Subscript", "node_id": 70, "value": {"ast_type": "Name", "node_id": 68, "id": "uint256"}, "slice": {"ast_type": "Index", "node_id": 69, "value": {"ast_type": "Int", "node_id": 67, "value": 3}}}},
+	{"ast_type": "VariableDecl", "node_id": 74, "target": {"ast_type": "Name", "node_id": 72, "id": "oracle"}, "annotation": {"ast_type": "Name", "node_id": 73, "id": "Oracle"}, "is_public": true, "is_immutable": true},
+	{"ast_type": "FunctionDef", "node_id": 80, "name": "__init__", "args": {"ast_type": "arguments", "node_id": 77, "args": [{"ast_type": "arg", "node_id": 76, "arg": "o", "annotation": {"ast_type": "Name", "node_id": 75, "id": "Oracle"}}], "defaults": []}, "decorator_list": [{"ast_type": "Name", "node_id": 78, "id": "deploy"}], "body": [{"ast_type": "Pass", "node_id": 79}]},
+	{"ast_type": "FunctionDef", "node_id": 93, "name": "deposit", "args": {"ast_type": "arguments", "node_id": 88, "args": [{"ast_type": "arg", "node_id": 82, "arg": "to", "annotation": {"ast_type": "Name", "node_id": 81, "id": "address"}}, {"ast_type": "arg", "node_id": 84, "arg": "amount", "annotation": {"ast_type": "Name", "node_id": 83, "id": "uint256"}}, {"ast_type": "arg", "node_id": 86, "arg": "roles", "annotation": {"ast_type": "Name", "node_id": 85, "id": "Roles"}}], "defaults": []}, "returns": {"ast_type": "Name", "node_id": 87, "id": "bool"}, "decorator_list": [{"ast_type": "Name", "node_id": 89, "id": "external"}, {"ast_type": "Name", "node_id": 90, "id": "payable"}, {"ast_type": "Name", "node_id": 91, "id": "nonreentrant"}], "body": [{"ast_type": "Pass", "node_id": 92}]},
+	{"ast_type": "FunctionDef", "node_id": 101, "name": "info", "args": {"ast_type": "arguments", "node_id": 97, "args": [{"ast_type": "arg", "node_id": 95, "arg": "id", "annotation": {"ast_type": "Name", "node_id": 94, "id": "uint256"}}], "defaults": []}, "returns": {"ast_type": "Name", "node_id": 96, "id": "Info"}, "decorator_list": [{"ast_type": "Name", "node_id": 98, "id": "external"}, {"ast_type": "Name", "node_id": 99, "id": "view"}], "body": [{"ast_type": "Pass", "node_id": 100}]},
+	{"ast_type": "FunctionDef", "node_id": 110, "name": "_check", "args": {"ast_type": "arguments", "node_id": 107, "args": [{"ast_type": "arg", "node_id": 106, "arg": "b", "annotation": {"ast_type": "Subscript", "node_id": 105, "value": {"ast_type": "Name", "node_id": 103, "id": "Bytes"}, "slice": {"ast_type": "Index", "node_id": 104, "value": {"ast_type": "Int", "node_id": 102, "value": 32}}}}], "defaults": []}, "decorator_list": [{"ast_type": "Name", "node_id": 108, "id": "internal"}], "body": [{"ast_type": "Pass", "node_id": 109}]},
+	{"ast_type": "FunctionDef", "node_id": 114, "name": "__default__", "args": {"ast_type": "arguments", "node_id": 111, "args": [], "defaults": []}, "decorator_list": [{"ast_type": "Name", "node_id": 112, "id": "external"}], "body": [{"ast_type": "Pass", "node_id": 113}]}]}}`
+
+func TestVyperAST(t *testing.T) {
+	tests := []struct {
+		name     string
+		contract string
+		want     []string
+	}{
+		{
+			name:     "interface",
+			contract: "Oracle",
+			want: []string{
+				"interface Oracle",
+				"function price(address) external view aea91078",
+			},
+		},
+		{
+			name:     "module",
+			contract: "Vault",
+			want: []string{
+				"contract Vault",
+				"constructor (address) nonpayable",
+				"fallback () nonpayable",
+				"function deposit(address,uint256,uint256) external payable 0efe6a8b",
+				"function info(uint256) external view 2e340599",
+				"function _check(bytes) internal nonpayable ",
+				"getter owner() 8da5cb5b",
+				"getter holders(uint256) 2a11ced0",
+				"getter oracle() 7dc0d1d0",
+				"getter balanceOf(address) 70a08231",
+				"getter infos(uint256) 28de3c9b",
+				"event Deposit(address,uint256)",
+				"variable address public owner",
+				"variable address[] public holders",
+				"variable uint256[3] internal slots",
+				"variable contract Oracle public oracle",
+				"constant uint256 MAX = (10 ** 18)",
+				"mapping mapping(address => uint256) balanceOf",
+				"mapping mapping(uint256 => struct Info) infos",
+				"struct Info {uint128 a; address b}",
+				"enum Roles {ADMIN, USER}",
+			},
+		},
+	}
+	contracts := loadFixtures(t, map[string]string{"Vault.json": vyperVault})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			contract, err := LookupContract(contracts, test.contract)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contract.ASTFlavour != ASTVyper {
+				t.Errorf("flavour = %s, want %s", contract.ASTFlavour, ASTVyper)
+			}
+			got := declarationRows(contract)
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("declarations =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// storage_diff.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/Simon-Busch/abi_simplifier/parser"
+)
+
+// storageDiffUsage describes the storage-diff subcommand.
+const storageDiffUsage = `usage: abi_simplifier storage-diff [--strict] <old> <new> [contract]
+
+Compares the storage layouts of two builds, each given as a data folder or an
+artifact file. Without a contract name, every contract found in both builds is
+compared. Exits with status 1 when a layout change would corrupt the storage
+of a deployed proxy, and 2 when the builds cannot be read, when a storage
+layout cannot be computed completely, such as when a base contract is missing,
+or with --strict when reading the builds reports any warning or error.`
+
+// runStorageDiff runs the storage-diff subcommand and returns the exit status.
+func runStorageDiff(args []string) int {
+	strict := len(args) > 0 && args[0] == "--strict"
+	if strict {
+		args = args[1:]
+	}
+	if len(args) < 2 || len(args) > 3 {
+		fmt.Fprintln(os.Stderr, storageDiffUsage)
+		return 2
+	}
+	oldContracts, err := loadBuild(args[0], strict)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing %s: %v\n", args[0], err)
+		return 2
+	}
+	newContracts, err := loadBuild(args[1], strict)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing %s: %v\n", args[1], err)
+		return 2
+	}
+
+	// Pairs of old and new contracts to compare
+	var pairs [][2]*parser.Contract
+	if len(args) == 3 {
+		oldContract, err := parser.LookupContract(oldContracts, args[2])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+			return 2
+		}
+		newContract, err := parser.LookupContract(newContracts, args[2])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", args[1], err)
+			return 2
+		}
+		pairs = append(pairs, [2]*parser.Contract{oldContract, newContract})
+	} else {
+		var keys []string
+		for key, contract := range oldContracts {
+			if _, ok := newContracts[key]; ok && contract.Kind == "contract" {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			pairs = append(pairs, [2]*parser.Contract{oldContracts[key], newContracts[key]})
+		}
+	}
+	if len(pairs) == 0 {
+		fmt.Fprintln(os.Stderr, "No contract found in both builds")
+		return 2
+	}
+
+	status := 0
+	for _, pair := range pairs {
+		oldLayout, newLayout, err := storageLayouts(pair, oldContracts, newContracts)
+		if err != nil {
+			// Never call a layout compatible when part of it is unknown
+			fmt.Printf("%s: INCOMPLETE\n", pair[1].QualifiedName())
+			fmt.Fprintf(os.Stderr, "%s: cannot check the upgrade, %v\n", pair[1].QualifiedName(), err)
+			status = 2
+			continue
+		}
+		changes := parser.CompareStorageUpgrade(oldLayout, newLayout)
+		verdict := "compatible"
+		if !parser.IsStorageCompatible(changes) {
+			verdict = "INCOMPATIBLE"
+			status = max(status, 1)
+		}
+		fmt.Printf("%s: %s\n", pair[1].QualifiedName(), verdict)
+		for _, change := range changes {
+			marker := " "
+			if change.Breaking {
+				marker = "!"
+			}
+			fmt.Printf("  %s %-10s %s\n", marker, change.Kind, change.Message)
+		}
+	}
+	return status
+}
+
+// storageLayouts returns the old and new storage layouts of a pair of
+// contracts, or an error when either could not be computed completely.
+func storageLayouts(pair [2]*parser.Contract, oldContracts map[string]*parser.Contract, newContracts map[string]*parser.Contract) ([]parser.StorageEntry, []parser.StorageEntry, error) {
+	oldLayout, _, err := parser.ContractStorageLayout(pair[0], oldContracts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("old storage layout incomplete: %w", err)
+	}
+	newLayout, _, err := parser.ContractStorageLayout(pair[1], newContracts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("new storage layout incomplete: %w", err)
+	}
+	return oldLayout, newLayout, nil
+}
+
+// loadBuild parses the contracts of a build. In strict mode, the diagnostics
+// are printed and the build rejected when any is a warning or an error.
+func loadBuild(path string, strict bool) (map[string]*parser.Contract, error) {
+	units, report, err := parser.LoadSourceUnits(path)
+	if err != nil {
+		return nil, err
+	}
+	if strict && report.Diagnostics.Failed() {
+		printDiagnostics(report.Diagnostics)
+		return nil, fmt.Errorf("%d errors, %d warnings", report.Diagnostics.Count(parser.SeverityError), report.Diagnostics.Count(parser.SeverityWarning))
+	}
+	return parser.CollectContracts(units), nil
+}
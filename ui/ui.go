@@ -6,13 +6,22 @@ import (
     "github.com/gizak/termui/v3/widgets"
 )
 
-func UpdateUI(contractsList *widgets.List, detailsList *widgets.List, codeParagraph *widgets.Paragraph, contractsListSelected bool, detailsListSelected bool) {
+// UpdateUI lays out and renders the panels. diagnosticsList is nil while the
+// diagnostics panel is hidden, and takes the bottom third of the screen
+// otherwise.
+func UpdateUI(contractsList *widgets.List, detailsList *widgets.List, codeParagraph *widgets.Paragraph, diagnosticsList *widgets.List, contractsListSelected bool, detailsListSelected bool) {
 	termWidth, termHeight := termui.TerminalDimensions()
+	panelsHeight := termHeight
+	if diagnosticsList != nil {
+		panelsHeight = termHeight * 2 / 3
+		diagnosticsList.SetRect(0, panelsHeight, termWidth, termHeight)
+		validateSelectedRow(diagnosticsList)
+	}
 
 	// Set sizes and positions
-	contractsList.SetRect(0, 0, termWidth/4, termHeight)
-	detailsList.SetRect(termWidth/4, 0, termWidth/2, termHeight)
-	codeParagraph.SetRect(termWidth/2, 0, termWidth, termHeight)
+	contractsList.SetRect(0, 0, termWidth/4, panelsHeight)
+	detailsList.SetRect(termWidth/4, 0, termWidth/2, panelsHeight)
+	codeParagraph.SetRect(termWidth/2, 0, termWidth, panelsHeight)
 
 	// Highlight selected widget
 	if contractsListSelected {
@@ -28,6 +37,9 @@ func UpdateUI(contractsList *widgets.List, detailsList *widgets.List, codeParagr
 	validateSelectedRow(detailsList)
 
 	termui.Render(contractsList, detailsList, codeParagraph)
+	if diagnosticsList != nil {
+		termui.Render(diagnosticsList)
+	}
 }
 
 func validateSelectedRow(list *widgets.List) {